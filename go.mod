@@ -1,3 +1,8 @@
 module github.com/hypebeast/go-osc
 
-go 1.16
+go 1.19
+
+require (
+	golang.org/x/net v0.21.0
+	golang.org/x/sys v0.17.0
+)
@@ -0,0 +1,389 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidAddress is wrapped by the errors returned for invalid OSC
+// addresses and address patterns, e.g. by ValidatePattern and when marshaling
+// a message whose address doesn't start with '/'.
+var ErrInvalidAddress = errors.New("invalid OSC address")
+
+// ValidateAddress checks whether addr can be used as the OSC address of a
+// message handler. Handler addresses may not contain the characters ' ', '#',
+// '*', ',', '?', '[', ']', '{' and '}', since they have a special meaning in
+// OSC address patterns, nor control characters. '/' may only separate
+// non-empty parts, so addresses can't contain "//" or end with '/'. The
+// returned error names the offending character. Use ValidatePattern for the
+// addresses of messages.
+func ValidateAddress(addr string) error {
+	for i := 0; i < len(addr); i++ {
+		c := addr[i]
+		switch {
+		case c < 0x20 || c == 0x7f:
+			return fmt.Errorf("%w: control character %q at offset %d", ErrInvalidAddress, c, i)
+		case strings.IndexByte(" #*,?[]{}", c) >= 0:
+			return fmt.Errorf("%w: character %q at offset %d", ErrInvalidAddress, c, i)
+		case c == '/' && i > 0 && addr[i-1] == '/':
+			return fmt.Errorf("%w: empty part at offset %d", ErrInvalidAddress, i)
+		}
+	}
+	if len(addr) > 1 && strings.HasSuffix(addr, "/") {
+		return fmt.Errorf("%w: %q ends with '/'", ErrInvalidAddress, addr)
+	}
+	return nil
+}
+
+// ValidatePattern checks whether pattern is a valid OSC address pattern, as
+// used for the addresses of messages. Unlike handler addresses, patterns may
+// contain the wildcards '*' and '?', character classes like '[a-z]' and
+// '[!abc]' as well as alternatives like '{foo,bar}'. Brackets and braces must
+// be closed and can't be nested, ',' is only allowed within braces. The
+// characters ' ' and '#' are not allowed at all and the pattern must start
+// with '/'.
+func ValidatePattern(pattern string) error {
+	if err := checkLeadingSlash(pattern); err != nil {
+		return err
+	}
+	if strings.ContainsAny(pattern, "# ") {
+		return fmt.Errorf("%w: pattern may not contain any characters in \" #\"", ErrInvalidAddress)
+	}
+
+	var open byte
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '[' || c == '{':
+			if open != 0 {
+				return fmt.Errorf("%w: nested %q at offset %d", ErrInvalidAddress, c, i)
+			}
+			open = c
+		case c == ']' || c == '}':
+			if (c == ']' && open != '[') || (c == '}' && open != '{') {
+				return fmt.Errorf("%w: unexpected %q at offset %d", ErrInvalidAddress, c, i)
+			}
+			open = 0
+		case c == ',' && open != '{':
+			return fmt.Errorf("%w: unexpected ',' at offset %d", ErrInvalidAddress, i)
+		case c == '/' && open != 0:
+			return fmt.Errorf("%w: unexpected '/' at offset %d", ErrInvalidAddress, i)
+		}
+	}
+	if open != 0 {
+		return fmt.Errorf("%w: unclosed %q", ErrInvalidAddress, open)
+	}
+	return nil
+}
+
+// SplitAddress returns the parts of the OSC address addr, which are separated
+// by '/'. The leading '/' is removed first, so SplitAddress("/a/b") returns
+// ["a" "b"]. An empty slice is returned for the root address "/".
+func SplitAddress(addr string) []string {
+	addr = strings.TrimPrefix(addr, "/")
+	if addr == "" {
+		return []string{}
+	}
+	return strings.Split(addr, "/")
+}
+
+// JoinAddress joins the given address parts to an OSC address, which is the
+// reverse of SplitAddress. JoinAddress() returns the root address "/".
+func JoinAddress(parts ...string) string {
+	return "/" + strings.Join(parts, "/")
+}
+
+// NormalizeAddress returns addr with runs of '/' collapsed to a single '/', so
+// NormalizeAddress("/a//b") returns "/a/b". Trailing slashes aren't stripped,
+// an error is returned for addresses like "/a/b/" instead, as well as for
+// addresses that don't start with '/'. The root address "/" is kept.
+func NormalizeAddress(addr string) (string, error) {
+	if err := checkLeadingSlash(addr); err != nil {
+		return "", err
+	}
+
+	normalized := addr
+	if strings.Contains(addr, "//") {
+		var b strings.Builder
+		b.Grow(len(addr))
+		for i := 0; i < len(addr); i++ {
+			if addr[i] != '/' || i == 0 || addr[i-1] != '/' {
+				b.WriteByte(addr[i])
+			}
+		}
+		normalized = b.String()
+	}
+	if len(normalized) > 1 && strings.HasSuffix(normalized, "/") {
+		return "", fmt.Errorf("%w: %q ends with '/'", ErrInvalidAddress, addr)
+	}
+	return normalized, nil
+}
+
+// checkLeadingSlash returns an error if addr doesn't start with '/', as
+// required for all OSC addresses.
+func checkLeadingSlash(addr string) error {
+	if !strings.HasPrefix(addr, "/") {
+		return fmt.Errorf("%w: %q must start with '/'", ErrInvalidAddress, addr)
+	}
+	return nil
+}
+
+// addressNode is a node of the address tree used by the StandardDispatcher to
+// look up handlers. Every node represents one part of an OSC address, the
+// parts being separated by '/'.
+type addressNode struct {
+	children map[string]*addressNode
+	handler  Handler
+}
+
+// newAddressNode returns an empty address tree node.
+func newAddressNode() *addressNode {
+	return &addressNode{children: make(map[string]*addressNode)}
+}
+
+// insert adds the handler for the given address to the tree. It returns false
+// if there is already a handler for the address.
+func (n *addressNode) insert(addr string, handler Handler) bool {
+	node := n
+	for _, part := range strings.Split(addr, "/") {
+		child, ok := node.children[part]
+		if !ok {
+			child = newAddressNode()
+			node.children[part] = child
+		}
+		node = child
+	}
+	if node.handler != nil {
+		return false
+	}
+	node.handler = handler
+	return true
+}
+
+// remove removes the handler for the given address from the tree and prunes
+// nodes that are no longer needed. It returns false if there is no handler
+// for the address.
+func (n *addressNode) remove(addr string) bool {
+	return n.removeParts(strings.Split(addr, "/"))
+}
+
+func (n *addressNode) removeParts(parts []string) bool {
+	if len(parts) == 0 {
+		if n.handler == nil {
+			return false
+		}
+		n.handler = nil
+		return true
+	}
+
+	child, ok := n.children[parts[0]]
+	if !ok || !child.removeParts(parts[1:]) {
+		return false
+	}
+	if child.handler == nil && len(child.children) == 0 {
+		delete(n.children, parts[0])
+	}
+	return true
+}
+
+// lookup returns the node for the given address or nil if there is none.
+func (n *addressNode) lookup(addr string) *addressNode {
+	node := n
+	for _, part := range strings.Split(addr, "/") {
+		node = node.children[part]
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// addresses returns the addresses of all handlers in the tree.
+func (n *addressNode) addresses() []string {
+	var addrs []string
+	n.walk(nil, func(parts []string) {
+		addrs = append(addrs, strings.Join(parts, "/"))
+	})
+	return addrs
+}
+
+// walk calls fn with the address parts of every node that has a handler.
+func (n *addressNode) walk(parts []string, fn func(parts []string)) {
+	if n.handler != nil {
+		fn(parts)
+	}
+	for name, child := range n.children {
+		child.walk(append(parts[:len(parts):len(parts)], name), fn)
+	}
+}
+
+// match calls fn for every handler whose address matches the given OSC
+// address pattern. Parts of the pattern without wildcards are looked up
+// directly, all others are matched against every child of the current node.
+// If traverse is set, '//' in the pattern matches any number of address
+// parts, including none.
+func (n *addressNode) match(pattern string, traverse bool, fn func(Handler)) {
+	parts := strings.Split(pattern, "/")
+	if !traverse {
+		n.matchParts(parts, false, func(node *addressNode) {
+			fn(node.handler)
+		})
+		return
+	}
+
+	// A handler may be reached in several ways if the pattern contains '//'
+	// more than once, but must only be called once
+	seen := make(map[*addressNode]bool)
+	visit := func(node *addressNode) {
+		if !seen[node] {
+			seen[node] = true
+			fn(node.handler)
+		}
+	}
+
+	// The empty part before the leading '/' isn't a '//'
+	if len(parts) > 1 && parts[0] == "" {
+		if child, ok := n.children[""]; ok {
+			child.matchParts(parts[1:], true, visit)
+		}
+		return
+	}
+	n.matchParts(parts, true, visit)
+}
+
+// matchParts calls visit for every node with a handler that matches parts.
+func (n *addressNode) matchParts(parts []string, traverse bool, visit func(*addressNode)) {
+	if len(parts) == 0 {
+		if n.handler != nil {
+			visit(n)
+		}
+		return
+	}
+
+	part := parts[0]
+	if traverse && part == "" && len(parts) > 1 {
+		n.matchDescendants(parts[1:], visit)
+		return
+	}
+	if !strings.ContainsAny(part, "*?[]{}") {
+		if child, ok := n.children[part]; ok {
+			child.matchParts(parts[1:], traverse, visit)
+		}
+		return
+	}
+	for name, child := range n.children {
+		if matchPart(part, name) {
+			child.matchParts(parts[1:], traverse, visit)
+		}
+	}
+}
+
+// matchDescendants matches parts against n and all of its descendants.
+func (n *addressNode) matchDescendants(parts []string, visit func(*addressNode)) {
+	n.matchParts(parts, true, visit)
+	for _, child := range n.children {
+		child.matchDescendants(parts, visit)
+	}
+}
+
+// matchAddress returns true if addr matches the OSC address pattern, part by
+// part, so wildcards never match '/'. The pattern must be valid.
+func matchAddress(pattern, addr string) bool {
+	patternParts := strings.Split(pattern, "/")
+	addrParts := strings.Split(addr, "/")
+	if len(patternParts) != len(addrParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if !matchPart(part, addrParts[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPart returns true if the address part name matches the pattern part.
+// It supports the OSC wildcards '?', '*', '[]' and '{,}'.
+func matchPart(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Collapse consecutive stars and try every possible split
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchPart(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+
+		case '[':
+			end := strings.IndexByte(pattern, ']')
+			if end < 0 || len(name) == 0 {
+				return false
+			}
+			if !matchCharClass(pattern[1:end], name[0]) {
+				return false
+			}
+			pattern, name = pattern[end+1:], name[1:]
+
+		case '{':
+			end := strings.IndexByte(pattern, '}')
+			if end < 0 {
+				return false
+			}
+			rest := pattern[end+1:]
+			for _, alt := range strings.Split(pattern[1:end], ",") {
+				if strings.HasPrefix(name, alt) && matchPart(rest, name[len(alt):]) {
+					return true
+				}
+			}
+			return false
+
+		default:
+			if len(name) == 0 || pattern[0] != name[0] {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+	}
+	return len(name) == 0
+}
+
+// matchCharClass returns true if c is matched by the character class, which
+// is the content between '[' and ']'. A leading '!' negates the class and
+// 'a-z' denotes a range of characters.
+func matchCharClass(class string, c byte) bool {
+	negate := false
+	if len(class) > 0 && class[0] == '!' {
+		negate = true
+		class = class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			matched = true
+		}
+	}
+	return matched != negate
+}
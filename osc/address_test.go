@@ -0,0 +1,451 @@
+package osc
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		ok   bool
+	}{
+		{"/address/test", true},
+		{"/a-b_c.d/1", true},
+		{"/address*/test", false},
+		{"/address/te?t", false},
+		{"/address/{a,b}", false},
+		{"/address/[ab]", false},
+		{"/address/#", false},
+		{"/address test", false},
+		{"/address,test", false},
+		{"/address\ttest", false},
+		{"/address\x7f", false},
+		{"/address//test", false},
+		{"/address/test/", false},
+		{"/", true},
+	} {
+		err := ValidateAddress(tt.addr)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateAddress(%q) error = %v, want ok = %t", tt.addr, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("ValidateAddress(%q) error = %v, want = %v", tt.addr, err, ErrInvalidAddress)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		ok      bool
+	}{
+		{"/address/test", true},
+		{"/address*/test", true},
+		{"/a/?/[a-z]/[!abc]/{foo,bar}", true},
+		{"/address/#", false},
+		{"/address test", false},
+		{"/a/[ab", false},
+		{"/a/{foo,bar", false},
+		{"/a/ab]", false},
+		{"/a/foo}", false},
+		{"/a/[{b}]", false},
+		{"/a/{[b]}", false},
+		{"/a/b,c", false},
+		{"/a/{b/c}", false},
+		{"address/test", false},
+		{"", false},
+	} {
+		err := ValidatePattern(tt.pattern)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidatePattern(%q) error = %v, want ok = %t", tt.pattern, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("ValidatePattern(%q) error = %v, want = %v", tt.pattern, err, ErrInvalidAddress)
+		}
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want []string
+	}{
+		{"/", []string{}},
+		{"", []string{}},
+		{"/a", []string{"a"}},
+		{"/a/b/c", []string{"a", "b", "c"}},
+		{"a/b", []string{"a", "b"}},
+		{"/a/", []string{"a", ""}},
+	} {
+		got := SplitAddress(tt.addr)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitAddress(%q) = %q, want = %q", tt.addr, got, tt.want)
+		}
+		if tt.addr == "" || tt.addr[0] != '/' {
+			continue
+		}
+		if got := JoinAddress(got...); got != tt.addr {
+			t.Errorf("JoinAddress(SplitAddress(%q)) = %q", tt.addr, got)
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want string
+		ok   bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/", "/", true},
+		{"//", "/", true},
+		{"/a//b", "/a/b", true},
+		{"//a///b", "/a/b", true},
+		{"/a/b/", "", false},
+		{"/a//b//", "", false},
+		{"a/b", "", false},
+		{"", "", false},
+	} {
+		got, err := NormalizeAddress(tt.addr)
+		if (err == nil) != tt.ok {
+			t.Errorf("NormalizeAddress(%q) error = %v, want ok = %t", tt.addr, err, tt.ok)
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeAddress(%q) = %q, want = %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPart(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"foo", "foo", true},
+		{"foo", "fo", false},
+		{"*", "foo", true},
+		{"*", "", true},
+		{"f*", "foo", true},
+		{"*o", "foo", true},
+		{"*x", "foo", false},
+		{"f**o", "foo", true},
+		{"f?o", "foo", true},
+		{"f?o", "fo", false},
+		{"[abc]", "b", true},
+		{"[abc]", "d", false},
+		{"[a-c]x", "bx", true},
+		{"[!a-c]x", "bx", false},
+		{"[!a-c]x", "dx", true},
+		{"{foo,bar}", "bar", true},
+		{"{foo,bar}", "baz", false},
+		{"{foo,bar}*", "barbaz", true},
+		{"[abc", "a", false},
+	} {
+		if got := matchPart(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchPart(%q, %q) = %t, want = %t", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStandardDispatcherMatching(t *testing.T) {
+	d := NewStandardDispatcher()
+	var got []string
+	for _, addr := range []string{"/a/b", "/a/c", "/a/b/c", "/x/y"} {
+		addr := addr
+		if err := d.AddMsgHandler(addr, func(msg *Message) {
+			got = append(got, addr)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.AddMsgHandler("/a/b", func(msg *Message) {}); err == nil {
+		t.Error("Expected error adding a handler for an existing address")
+	}
+	if err := d.AddMsgHandler("*", func(msg *Message) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	wantAddrs := []string{"/a/b", "/a/b/c", "/a/c", "/x/y"}
+	if got := d.Addresses(); !reflect.DeepEqual(got, wantAddrs) {
+		t.Errorf("Addresses() = %v, want = %v", got, wantAddrs)
+	}
+
+	for _, tt := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"/a/b", []string{"/a/b"}},
+		{"/a", nil},
+		{"/b", nil},
+		{"/a/*", []string{"/a/b", "/a/c"}},
+		{"/a/*/*", []string{"/a/b/c"}},
+		{"/*/*", []string{"/a/b", "/a/c", "/x/y"}},
+		{"/a/b*", []string{"/a/b"}},
+		{"/a/{b,c}", []string{"/a/b", "/a/c"}},
+		{"/*/b/c", []string{"/a/b/c"}},
+		{"/?/?", []string{"/a/b", "/a/c", "/x/y"}},
+		{"/a/[!b]", []string{"/a/c"}},
+	} {
+		got = nil
+		d.Dispatch(NewMessage(tt.pattern))
+		sort.Strings(got)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: dispatched to %v, want = %v", tt.pattern, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: dispatched to %v, want = %v", tt.pattern, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestStandardDispatcherPathTraversal(t *testing.T) {
+	d := NewStandardDispatcher()
+	var got []string
+	for _, addr := range []string{"/synth/freq", "/synth/a/freq", "/synth/a/b/freq", "/synth/a/gain", "/fx/freq"} {
+		addr := addr
+		if err := d.AddMsgHandler(addr, func(msg *Message) {
+			got = append(got, addr)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		pattern  string
+		traverse bool
+		want     []string
+	}{
+		{"/synth//freq", false, nil},
+		{"/synth//freq", true, []string{"/synth/a/b/freq", "/synth/a/freq", "/synth/freq"}},
+		{"//freq", true, []string{"/fx/freq", "/synth/a/b/freq", "/synth/a/freq", "/synth/freq"}},
+		{"/synth//a//freq", true, []string{"/synth/a/b/freq", "/synth/a/freq"}},
+		{"/synth//g*", true, []string{"/synth/a/gain"}},
+		{"/synth/a/freq", true, []string{"/synth/a/freq"}},
+	} {
+		d.SetPathTraversal(tt.traverse)
+		got = nil
+		d.Dispatch(NewMessage(tt.pattern))
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s (traverse = %t): dispatched to %v, want = %v", tt.pattern, tt.traverse, got, tt.want)
+		}
+	}
+}
+
+func TestStandardDispatcherNormalizeAddresses(t *testing.T) {
+	d := NewStandardDispatcher()
+	var got []string
+	handler := func(name string) HandlerFunc {
+		return func(msg *Message) {
+			got = append(got, name)
+		}
+	}
+	if err := d.AddMsgHandler("/a/b", handler("/a/b")); err != nil {
+		t.Fatal(err)
+	}
+	d.Dispatch(NewMessage("/a//b"))
+	if got != nil {
+		t.Errorf("dispatched to %v without normalization", got)
+	}
+
+	d.SetNormalizeAddresses(true)
+	if err := d.AddMsgHandler("/c//d", handler("/c/d")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddMsgHandler("/c/", handler("")); err == nil {
+		t.Error("expected an error adding a handler with a trailing slash")
+	}
+	if want := []string{"/a/b", "/c/d"}; !reflect.DeepEqual(d.Addresses(), want) {
+		t.Errorf("Addresses() = %v, want = %v", d.Addresses(), want)
+	}
+
+	for _, tt := range []struct {
+		pattern  string
+		traverse bool
+		want     []string
+	}{
+		{"/a//b", false, []string{"/a/b"}},
+		{"//c/d", false, []string{"/c/d"}},
+		{"/c/d/", false, nil},
+		{"//d", true, []string{"/c/d"}},
+	} {
+		d.SetPathTraversal(tt.traverse)
+		got = nil
+		d.Dispatch(NewMessage(tt.pattern))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s (traverse = %t): dispatched to %v, want = %v", tt.pattern, tt.traverse, got, tt.want)
+		}
+	}
+}
+
+func TestStandardDispatcherNormalizeAddressesRoundTrip(t *testing.T) {
+	d := NewStandardDispatcher()
+	d.SetNormalizeAddresses(true)
+	var got []string
+	handler := func(name string) HandlerFunc {
+		return func(msg *Message) {
+			got = append(got, name)
+		}
+	}
+
+	for _, addr := range []string{"/c//d", "//e/f", "/g"} {
+		if err := d.AddMsgHandler(addr, handler(addr)); err != nil {
+			t.Fatalf("AddMsgHandler(%q) error = %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"/c//d", "/e/f", "//g"} {
+		if err := d.RemoveMsgHandler(addr); err != nil {
+			t.Errorf("RemoveMsgHandler(%q) error = %v", addr, err)
+		}
+	}
+	if addrs := d.Addresses(); len(addrs) != 0 {
+		t.Errorf("Addresses() = %v after removing all handlers, want none", addrs)
+	}
+	if err := d.RemoveMsgHandler("/c/d/"); err == nil {
+		t.Error("expected an error removing a handler with a trailing slash")
+	}
+
+	if err := d.AddPrefixHandler("/synth//a/", handler("/synth/a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddPrefixHandler("/synth/a", handler("")); err == nil {
+		t.Error("expected an error adding the normalized prefix again")
+	}
+	for _, pattern := range []string{"/synth/a/freq", "/synth//a/freq"} {
+		got = nil
+		d.Dispatch(NewMessage(pattern))
+		if want := []string{"/synth/a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: dispatched to %v, want = %v", pattern, got, want)
+		}
+	}
+}
+
+func TestStandardDispatcherPrefixHandler(t *testing.T) {
+	d := NewStandardDispatcher()
+	var got []string
+	handler := func(name string) HandlerFunc {
+		return func(msg *Message) {
+			got = append(got, name)
+		}
+	}
+	if err := d.AddMsgHandler("/synth/a/freq", handler("exact")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddPrefixHandler("/synth", handler("synth")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddPrefixHandler("/synth/b/", handler("synth/b")); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"/synth", "synth", "", "/a*"} {
+		if err := d.AddPrefixHandler(prefix, handler("")); err == nil {
+			t.Errorf("AddPrefixHandler(%q) expected an error", prefix)
+		}
+	}
+
+	for _, tt := range []struct {
+		addr string
+		want []string
+	}{
+		{"/synth/a/freq", []string{"exact"}},
+		{"/synth/a/gain", []string{"synth"}},
+		{"/synth", []string{"synth"}},
+		{"/synth/b/c/freq", []string{"synth/b"}},
+		{"/synthesizer", nil},
+		{"/fx/synth", nil},
+	} {
+		got = nil
+		d.Dispatch(NewMessage(tt.addr))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dispatched to %v, want = %v", tt.addr, got, tt.want)
+		}
+	}
+
+	// The root prefix catches all messages without another handler
+	if err := d.AddPrefixHandler("/", handler("root")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddPrefixHandler("/", handler("")); err == nil {
+		t.Error("AddPrefixHandler(\"/\") expected an error for the second root handler")
+	}
+	for _, tt := range []struct {
+		addr string
+		want []string
+	}{
+		{"/synth/a/freq", []string{"exact"}},
+		{"/synth/a/gain", []string{"synth"}},
+		{"/synthesizer", []string{"root"}},
+		{"/fx/synth", []string{"root"}},
+	} {
+		got = nil
+		d.Dispatch(NewMessage(tt.addr))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dispatched to %v, want = %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestStandardDispatcherRemoveAndReplace(t *testing.T) {
+	d := NewStandardDispatcher()
+	var got string
+	handler := func(name string) HandlerFunc {
+		return func(msg *Message) { got = name }
+	}
+
+	if err := d.RemoveMsgHandler("/a/b"); err == nil {
+		t.Error("Expected error removing a missing handler")
+	}
+	if err := d.AddMsgHandler("/a/b", handler("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddMsgHandler("/a/b/c", handler("child")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetMsgHandler("/a/b", handler("second")); err != nil {
+		t.Fatal(err)
+	}
+	d.Dispatch(NewMessage("/a/b"))
+	if got != "second" {
+		t.Errorf("dispatched to %q, want = %q", got, "second")
+	}
+
+	if err := d.RemoveMsgHandler("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	got = ""
+	d.Dispatch(NewMessage("/a/b"))
+	if got != "" {
+		t.Errorf("dispatched to removed handler %q", got)
+	}
+	if err := d.RemoveMsgHandler("/a/b"); err == nil {
+		t.Error("Expected error removing a handler twice")
+	}
+	if want := []string{"/a/b/c"}; !reflect.DeepEqual(d.Addresses(), want) {
+		t.Errorf("Addresses() = %v, want = %v", d.Addresses(), want)
+	}
+
+	if err := d.RemoveMsgHandler("/a/b/c"); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.handlers.children) != 0 {
+		t.Error("Expected empty nodes to be pruned")
+	}
+
+	if err := d.RemoveMsgHandler("*"); err == nil {
+		t.Error("Expected error removing a missing default handler")
+	}
+	if err := d.AddMsgHandler("*", handler("default")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RemoveMsgHandler("*"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,88 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// DNS service discovery (DNS-SD) service types of OSC servers, as used by
+// controllers like TouchOSC to discover them via mDNS/Bonjour.
+const (
+	ServiceTypeUDP = "_osc._udp"
+	ServiceTypeTCP = "_osc._tcp"
+)
+
+// Advertiser publishes network services via DNS service discovery, usually
+// over mDNS/Bonjour. This package doesn't implement mDNS itself, so that it
+// doesn't depend on a particular library. Wrap one instead, e.g. with
+// github.com/grandcat/zeroconf:
+//
+//	osc.AdvertiserFunc(func(instance, service string, port int) (func(), error) {
+//		s, err := zeroconf.Register(instance, service, "local.", port, nil, nil)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return s.Shutdown, nil
+//	})
+type Advertiser interface {
+	// Register announces the service instance with the given name, service
+	// type, e.g. ServiceTypeUDP, and port. The returned function withdraws
+	// the announcement.
+	Register(instance, service string, port int) (deregister func(), err error)
+}
+
+// AdvertiserFunc implements the Advertiser interface with a function.
+type AdvertiserFunc func(instance, service string, port int) (func(), error)
+
+// Register calls f. Implements the Advertiser interface.
+func (f AdvertiserFunc) Register(instance, service string, port int) (func(), error) {
+	return f(instance, service, port)
+}
+
+// Advertise announces the server with the given instance name as
+// ServiceTypeUDP service on the port of LocalAddr, so that controllers can
+// discover it without entering its address. The server must be serving. The
+// announcement is withdrawn when the server stops serving the connection,
+// e.g. after CloseConnection, or when the returned function is called.
+func (s *Server) Advertise(a Advertiser, instance string) (stop func(), err error) {
+	s.mu.Lock()
+	if len(s.conns) == 0 {
+		s.mu.Unlock()
+		return nil, errors.New("server isn't serving")
+	}
+	c := s.conns[0]
+	s.mu.Unlock()
+
+	addr, ok := c.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("can't advertise a server on a %s connection", c.LocalAddr().Network())
+	}
+	deregister, err := a.Register(instance, ServiceTypeUDP, addr.Port)
+	if err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	stop = func() { once.Do(deregister) }
+
+	s.mu.Lock()
+	serving := false
+	for _, conn := range s.conns {
+		serving = serving || conn == c
+	}
+	if serving {
+		if s.adverts == nil {
+			s.adverts = make(map[net.PacketConn][]func())
+		}
+		s.adverts[c] = append(s.adverts[c], stop)
+	}
+	s.mu.Unlock()
+
+	if !serving {
+		// The server stopped serving while the service was registered
+		stop()
+		return nil, errors.New("server isn't serving")
+	}
+	return stop, nil
+}
@@ -0,0 +1,118 @@
+package osc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAdvertiser records the registered services.
+type fakeAdvertiser struct {
+	mu       sync.Mutex
+	services map[string]int // Registered "instance service" with their ports
+	stopped  int
+}
+
+func (a *fakeAdvertiser) Register(instance, service string, port int) (func(), error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.services == nil {
+		a.services = make(map[string]int)
+	}
+	key := instance + " " + service
+	a.services[key] = port
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.services, key)
+		a.stopped++
+	}, nil
+}
+
+func (a *fakeAdvertiser) state() (map[string]int, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	services := make(map[string]int)
+	for k, v := range a.services {
+		services[k] = v
+	}
+	return services, a.stopped
+}
+
+func TestServerAdvertise(t *testing.T) {
+	a := &fakeAdvertiser{}
+	server := &Server{}
+	if _, err := server.Advertise(a, "synth"); err == nil {
+		t.Error("expected an error advertising a server that isn't serving")
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeContext(ctx, conn)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); server.LocalAddr() == nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the server to serve")
+		}
+	}
+
+	stop, err := server.Advertise(a, "synth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Advertise(a, "mixer"); err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	services, _ := a.state()
+	for _, instance := range []string{"synth", "mixer"} {
+		if got := services[instance+" "+ServiceTypeUDP]; got != port {
+			t.Errorf("%s: registered port %d, want = %d", instance, got, port)
+		}
+	}
+
+	// Stopping an advertisement manually withdraws it only once
+	stop()
+	stop()
+	if services, stopped := a.state(); len(services) != 1 || stopped != 1 {
+		t.Errorf("after stop(): services = %v, stopped = %d, want 1 service and 1 stopped", services, stopped)
+	}
+
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("ServeContext() error = %v, want = %v", err, context.Canceled)
+	}
+	if services, stopped := a.state(); len(services) != 0 || stopped != 2 {
+		t.Errorf("after serving: services = %v, stopped = %d, want none and 2 stopped", services, stopped)
+	}
+}
+
+func TestServerAdvertiseError(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	server := &Server{}
+	if err := server.addConn(conn); err != nil {
+		t.Fatal(err)
+	}
+	defer server.removeConn(conn)
+
+	errRegister := errors.New("register failed")
+	_, err = server.Advertise(AdvertiserFunc(func(instance, service string, port int) (func(), error) {
+		return nil, errRegister
+	}), "synth")
+	if err != errRegister {
+		t.Errorf("Advertise() error = %v, want = %v", err, errRegister)
+	}
+}
@@ -0,0 +1,129 @@
+package osc
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchPacket is a named packet used by the benchmarks.
+type benchPacket struct {
+	name   string
+	packet Packet
+}
+
+// benchPackets returns representative packets for the benchmarks: a small
+// message like a fader update, a large message with many arguments of mixed
+// types and a blob, and a bundle of small messages.
+func benchPackets(b *testing.B) []benchPacket {
+	b.Helper()
+
+	large := NewMessage("/synth/voice/1/params")
+	for i := 0; i < 8; i++ {
+		large.Append(int32(i), float32(i)/8, fmt.Sprintf("param%d", i), int64(i)<<40)
+	}
+	large.Append(true, nil, float64(0.5), make([]byte, 4096))
+
+	var msgs []Packet
+	for i := 0; i < 16; i++ {
+		msgs = append(msgs, NewMessage(fmt.Sprintf("/mixer/channel/%d/fader", i), float32(i)/16))
+	}
+	bundle, err := NewImmediateBundle(msgs...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return []benchPacket{
+		{"small", NewMessage("/mixer/channel/1/fader", float32(0.5))},
+		{"large", large},
+		{"bundle", bundle},
+	}
+}
+
+func BenchmarkMarshalMessage(b *testing.B) {
+	for _, bp := range benchPackets(b) {
+		b.Run(bp.name, func(b *testing.B) {
+			data, err := bp.packet.MarshalBinary()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := bp.packet.MarshalBinary(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParsePacket(b *testing.B) {
+	for _, bp := range benchPackets(b) {
+		b.Run(bp.name, func(b *testing.B) {
+			data, err := bp.packet.MarshalBinary()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ParsePacketRaw(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	d := NewStandardDispatcher()
+	for i := 0; i < 16; i++ {
+		if err := d.AddMsgHandler(fmt.Sprintf("/mixer/channel/%d/fader", i), func(msg *Message) {}); err != nil {
+			b.Fatal(err)
+		}
+		if err := d.AddMsgHandler(fmt.Sprintf("/mixer/channel/%d/mute", i), func(msg *Message) {}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := d.AddMsgHandler("/synth/voice/1/params", func(msg *Message) {}); err != nil {
+		b.Fatal(err)
+	}
+
+	packets := append(benchPackets(b),
+		benchPacket{"pattern", NewMessage("/mixer/channel/*/{fader,mute}", float32(0))})
+	for _, bp := range packets {
+		b.Run(bp.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d.Dispatch(bp.packet)
+			}
+		})
+	}
+}
+
+func BenchmarkMarshalFloat32Array(b *testing.B) {
+	floats := make([]float32, 512)
+	boxed := make([]interface{}, len(floats))
+	for i := range floats {
+		floats[i] = float32(i) / 512
+		boxed[i] = floats[i]
+	}
+	slice := NewMessage("/fft")
+	slice.AppendFloat32Slice(floats)
+
+	for _, bp := range []benchPacket{
+		{"slice", slice},
+		{"interface", NewMessage("/fft", boxed)},
+	} {
+		b.Run(bp.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := bp.packet.MarshalBinary(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
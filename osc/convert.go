@@ -0,0 +1,160 @@
+package osc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// AsFloat64 returns the argument at index as float64. Arguments of all
+// numeric Go types, i.e. of all integer and float types, are converted, so
+// it doesn't matter whether the sender used an integer or a float. An error
+// is returned for other types and if there is no argument at index.
+func (msg *Message) AsFloat64(index int) (float64, error) {
+	arg, err := msg.argument(index)
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := floatValue(arg); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("argument %d is not a number: %T", index, arg)
+}
+
+// AsInt64 returns the argument at index as int64. Arguments of all numeric
+// Go types, i.e. of all integer and float types, are converted, floats are
+// truncated towards zero. An error is returned for other types, for values
+// that are NaN or out of the range of int64 and if there is no argument at
+// index.
+func (msg *Message) AsInt64(index int) (int64, error) {
+	arg, err := msg.argument(index)
+	if err != nil {
+		return 0, err
+	}
+	if i, ok := integerValue(arg); ok {
+		return i, nil
+	}
+	switch t := arg.(type) {
+	case float32:
+		return floatToInt64(index, float64(t))
+	case float64:
+		return floatToInt64(index, t)
+	case uint, uint64:
+		return 0, fmt.Errorf("argument %d overflows int64: %v", index, t)
+	}
+	return 0, fmt.Errorf("argument %d is not a number: %T", index, arg)
+}
+
+// AsFloat32Slice returns the argument at index, which must be an array of
+// float32 elements, as []float32. Arrays of received messages are decoded as
+// []interface{}, whose elements are copied; arguments appended with
+// AppendFloat32Slice are returned as is. An error is returned for other
+// arguments, including arrays with elements of other types, and if there is
+// no argument at index.
+func (msg *Message) AsFloat32Slice(index int) ([]float32, error) {
+	arg, err := msg.argument(index)
+	if err != nil {
+		return nil, err
+	}
+	switch t := arg.(type) {
+	case []float32:
+		return t, nil
+	case []interface{}:
+		floats := make([]float32, len(t))
+		for i, elem := range t {
+			f, ok := elem.(float32)
+			if !ok {
+				return nil, fmt.Errorf("element %d of argument %d is not a float32: %T", i, index, elem)
+			}
+			floats[i] = f
+		}
+		return floats, nil
+	}
+	return nil, fmt.Errorf("argument %d is not an array: %T", index, arg)
+}
+
+// NumericEqual reports whether the arguments a and b are equal, treating
+// numbers of all numeric types as equal if their values are, e.g. int32(1),
+// int64(1) and float32(1). Integers are compared exactly, floats as float64.
+// Other arguments are compared like by Message.Equals. Use it with
+// Message.EqualsFunc.
+func NumericEqual(a, b interface{}) bool {
+	ia, intA := integerValue(a)
+	ib, intB := integerValue(b)
+	if intA && intB {
+		return ia == ib
+	}
+	fa, numA := floatValue(a)
+	fb, numB := floatValue(b)
+	if numA && numB {
+		return fa == fb
+	}
+	return reflect.DeepEqual(normalizeArgument(a), normalizeArgument(b))
+}
+
+// integerValue returns the value of an argument of an integer type. It
+// returns false for unsigned values out of the range of int64.
+func integerValue(arg interface{}) (int64, bool) {
+	switch t := arg.(type) {
+	case int8:
+		return int64(t), true
+	case int16:
+		return int64(t), true
+	case int32:
+		return int64(t), true
+	case int64:
+		return t, true
+	case int:
+		return int64(t), true
+	case uint8:
+		return int64(t), true
+	case uint16:
+		return int64(t), true
+	case uint32:
+		return int64(t), true
+	case uint:
+		if uint64(t) <= math.MaxInt64 {
+			return int64(t), true
+		}
+	case uint64:
+		if t <= math.MaxInt64 {
+			return int64(t), true
+		}
+	}
+	return 0, false
+}
+
+// floatValue returns the value of a numeric argument as float64.
+func floatValue(arg interface{}) (float64, bool) {
+	if i, ok := integerValue(arg); ok {
+		return float64(i), true
+	}
+	switch t := arg.(type) {
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	case uint:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	}
+	return 0, false
+}
+
+// argument returns the argument at index.
+func (msg *Message) argument(index int) (interface{}, error) {
+	if index < 0 || index >= len(msg.Arguments) {
+		return nil, fmt.Errorf("argument index %d out of range [0, %d)", index, len(msg.Arguments))
+	}
+	return msg.Arguments[index], nil
+}
+
+// floatToInt64 converts the argument f to int64, truncating it.
+func floatToInt64(index int, f float64) (int64, error) {
+	// -2^63 is exactly representable, 2^63 is the first value out of range
+	if math.IsNaN(f) || f < math.MinInt64 || f >= -math.MinInt64 {
+		return 0, fmt.Errorf("argument %d overflows int64: %v", index, f)
+	}
+	return int64(f), nil
+}
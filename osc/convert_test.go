@@ -0,0 +1,117 @@
+package osc
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMessage_AsFloat64(t *testing.T) {
+	msg := NewMessage("/num", int32(1), int64(2), 3, float32(4.5), float64(5.25),
+		int8(-6), int16(7), uint8(8), uint16(9), uint32(10), uint(11), uint64(math.MaxUint64), "six")
+	for i, want := range []float64{1, 2, 3, 4.5, 5.25, -6, 7, 8, 9, 10, 11, math.MaxUint64} {
+		got, err := msg.AsFloat64(i)
+		if err != nil {
+			t.Errorf("AsFloat64(%d) returned error: %s", i, err)
+		} else if got != want {
+			t.Errorf("AsFloat64(%d) = %v, want = %v", i, got, want)
+		}
+	}
+	for _, i := range []int{12, 13, -1} {
+		if _, err := msg.AsFloat64(i); err == nil {
+			t.Errorf("AsFloat64(%d) expected an error", i)
+		}
+	}
+}
+
+func TestMessage_AsInt64(t *testing.T) {
+	for _, tt := range []struct {
+		arg  interface{}
+		want int64
+		ok   bool
+	}{
+		{int32(-1), -1, true},
+		{int64(math.MaxInt64), math.MaxInt64, true},
+		{7, 7, true},
+		{int8(-8), -8, true},
+		{int16(math.MinInt16), math.MinInt16, true},
+		{uint8(255), 255, true},
+		{uint16(math.MaxUint16), math.MaxUint16, true},
+		{uint32(math.MaxUint32), math.MaxUint32, true},
+		{uint(12), 12, true},
+		{uint64(math.MaxInt64), math.MaxInt64, true},
+		{uint64(math.MaxInt64 + 1), 0, false},
+		{float32(2.75), 2, true},
+		{float64(-2.75), -2, true},
+		{float64(math.MinInt64), math.MinInt64, true},
+		{float64(math.MaxInt64), 0, false},
+		{float32(1e30), 0, false},
+		{math.NaN(), 0, false},
+		{math.Inf(-1), 0, false},
+		{true, 0, false},
+		{[]byte{1}, 0, false},
+	} {
+		got, err := NewMessage("/num", tt.arg).AsInt64(0)
+		if (err == nil) != tt.ok {
+			t.Errorf("AsInt64() for %T(%v) error = %v, want ok = %t", tt.arg, tt.arg, err, tt.ok)
+		}
+		if got != tt.want {
+			t.Errorf("AsInt64() for %T(%v) = %d, want = %d", tt.arg, tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestMessage_AsFloat32Slice(t *testing.T) {
+	msg := NewMessage("/num",
+		[]float32{1, 2.5},
+		[]interface{}{float32(3), float32(-4)},
+		[]interface{}{},
+		[]interface{}{float32(1), int32(2)},
+		float32(5),
+	)
+	for i, want := range [][]float32{{1, 2.5}, {3, -4}, {}} {
+		got, err := msg.AsFloat32Slice(i)
+		if err != nil {
+			t.Errorf("AsFloat32Slice(%d) returned error: %s", i, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("AsFloat32Slice(%d) = %v, want = %v", i, got, want)
+		}
+	}
+	for _, i := range []int{3, 4, 5} {
+		if _, err := msg.AsFloat32Slice(i); err == nil {
+			t.Errorf("AsFloat32Slice(%d) expected an error", i)
+		}
+	}
+}
+
+func TestNumericEqual(t *testing.T) {
+	for _, tt := range []struct {
+		a, b interface{}
+		want bool
+	}{
+		{int32(1), float32(1), true},
+		{int32(1), int64(1), true},
+		{int64(1), float64(1), true},
+		{float32(0.5), float64(0.5), true},
+		{uint8(7), int32(7), true},
+		{uint32(math.MaxUint32), int64(math.MaxUint32), true},
+		{uint(math.MaxUint32), int64(math.MaxUint32), true},
+		{int16(-1), float32(-1), true},
+		{1, int64(1), true},
+		{int32(1), float32(1.5), false},
+		{int64(math.MaxInt64), int64(math.MaxInt64 - 1), false},
+		{float32(0.1), float64(0.1), false},
+		{"1", int32(1), false},
+		{"a", "a", true},
+		{[]byte{1}, []byte{1}, true},
+		{Blob{1}, []byte{1}, true},
+		{Blob{1}, []byte{2}, false},
+		{nil, nil, true},
+		{nil, int32(0), false},
+		{true, int32(1), false},
+	} {
+		if got := NumericEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("NumericEqual(%T(%v), %T(%v)) = %t, want = %t", tt.a, tt.a, tt.b, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package osc
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Differentiated Services Code Points (RFC 2474) for Client.SetDSCP. Routers
+// and switches configured for QoS forward packets with a higher class first,
+// which reduces the jitter of OSC control traffic on shared networks.
+//
+// DSCPExpedited is recommended for real-time control, e.g. of instruments or
+// lighting, as it is also used for audio over IP (AES67). DSCPCS5 is a less
+// aggressive choice for signaling traffic, DSCPAF41 suits interactive
+// traffic that may be queued briefly. Many networks ignore or rewrite the
+// field unless configured otherwise.
+const (
+	DSCPBestEffort = 0  // Default forwarding
+	DSCPAF41       = 34 // Assured forwarding, class 4, low drop precedence
+	DSCPCS5        = 40 // Class selector 5
+	DSCPExpedited  = 46 // Expedited forwarding (EF)
+)
+
+// SetDSCP sets the Differentiated Services Code Point of the packets sent by
+// the client, i.e. the upper 6 bits of the IPv4 ToS or IPv6 traffic class
+// field, so that the network can prioritize them. See DSCPExpedited for
+// recommended values. Zero, the default, keeps the operating system's
+// setting. It only applies to UDP; clients created with NewClientUnix or
+// Pipe ignore it. An error is returned if dscp isn't between 0 and 63. If
+// the platform doesn't support setting the field, sending fails with an
+// error saying so.
+func (c *Client) SetDSCP(dscp int) error {
+	if dscp < 0 || dscp > 63 {
+		return fmt.Errorf("invalid DSCP %d, must be between 0 and 63", dscp)
+	}
+	c.dscp = dscp
+	return nil
+}
+
+// DSCP returns the Differentiated Services Code Point set with SetDSCP.
+func (c *Client) DSCP() int { return c.dscp }
+
+// setDSCP sets the DSCP of packets sent over the UDP connection conn.
+func setDSCP(conn net.Conn, dscp int) error {
+	raddr, ok := conn.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	if raddr.IP.To4() != nil {
+		err = ipv4.NewConn(conn).SetTOS(dscp << 2)
+	} else {
+		err = ipv6.NewConn(conn).SetTrafficClass(dscp << 2)
+	}
+	if err != nil {
+		return fmt.Errorf("setting DSCP %d isn't supported: %w", dscp, err)
+	}
+	return nil
+}
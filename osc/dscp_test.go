@@ -0,0 +1,53 @@
+package osc
+
+import (
+	"runtime"
+	"testing"
+
+	"golang.org/x/net/ipv4"
+)
+
+func TestClientSetDSCP(t *testing.T) {
+	c := NewClient("127.0.0.1", 8765)
+	for _, tt := range []struct {
+		dscp    int
+		wantErr bool
+	}{
+		{DSCPBestEffort, false},
+		{DSCPExpedited, false},
+		{63, false},
+		{-1, true},
+		{64, true},
+	} {
+		if err := c.SetDSCP(tt.dscp); (err != nil) != tt.wantErr {
+			t.Errorf("SetDSCP(%d) error = %v, wantErr = %v", tt.dscp, err, tt.wantErr)
+		}
+	}
+	if got := c.DSCP(); got != 63 {
+		t.Errorf("DSCP() = %d, want = 63", got)
+	}
+}
+
+func TestClientDSCP(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("reading the ToS field isn't supported on", runtime.GOOS)
+	}
+
+	c, err := NewClientWithOptions("127.0.0.1", 8765, WithDSCP(DSCPExpedited))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := c.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tos, err := ipv4.NewConn(conn).TOS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := DSCPExpedited << 2; tos != want {
+		t.Errorf("TOS = %#x, want = %#x", tos, want)
+	}
+}
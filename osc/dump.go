@@ -0,0 +1,132 @@
+package osc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Dump returns the message in the one-line format of liblo's oscdump tool:
+// the address, the type tags without the leading ',' and the arguments,
+// separated by spaces. An empty string is returned if the message contains
+// arguments of unsupported types.
+func (msg *Message) Dump() string {
+	if msg == nil {
+		return ""
+	}
+
+	tags, err := msg.TypeTags()
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg.Address)
+	sb.WriteByte(' ')
+	sb.WriteString(tags[1:])
+	for _, arg := range msg.Arguments {
+		sb.WriteByte(' ')
+		sb.WriteString(dumpArgument(arg))
+	}
+	return sb.String()
+}
+
+// Dump returns all messages of the bundle and its nested bundles in the format
+// of liblo's oscdump tool, one message per line. Every line is prefixed with
+// the timetag of the enclosing bundle as seconds and fraction in hexadecimal.
+func (b *Bundle) Dump() string {
+	var lines []string
+	b.dumpLines(&lines)
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bundle) dumpLines(lines *[]string) {
+	prefix := dumpTimetag(b.Timetag)
+	for _, msg := range b.Messages {
+		*lines = append(*lines, prefix+" "+msg.Dump())
+	}
+	for _, bundle := range b.Bundles {
+		bundle.dumpLines(lines)
+	}
+}
+
+// dumpArgument formats the argument like liblo's lo_arg_pp function.
+func dumpArgument(arg interface{}) string {
+	switch t := arg.(type) {
+	case int32, int64, int, int8, int16, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", t)
+	case float32:
+		return dumpFloat(float64(t))
+	case float64:
+		return dumpFloat(t)
+	case string:
+		return `"` + t + `"`
+	case []byte:
+		return dumpBlob(t)
+	case Blob:
+		return dumpBlob(t)
+	case *blobReader:
+		return fmt.Sprintf("[%d byte blob]", t.size)
+	case UnknownArg:
+		return fmt.Sprintf("[%d bytes]", len(t.Data))
+	case []interface{}:
+		elems := make([]string, len(t))
+		for i, elem := range t {
+			elems[i] = dumpArgument(elem)
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case []float32:
+		elems := make([]string, len(t))
+		for i, elem := range t {
+			elems[i] = dumpFloat(float64(elem))
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case Timetag:
+		return dumpTimetag(t)
+	case bool:
+		if t {
+			return "#T"
+		}
+		return "#F"
+	case nil:
+		return "Nil"
+	}
+	return ""
+}
+
+// dumpFloat formats f like C's printf("%f").
+func dumpFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return fmt.Sprintf("%f", f)
+}
+
+// dumpBlob formats a blob. Blobs up to 12 bytes are printed byte by byte,
+// larger blobs only with their size.
+func dumpBlob(b []byte) string {
+	if len(b) > 12 {
+		return fmt.Sprintf("[%d byte blob]", len(b))
+	}
+
+	parts := make([]string, len(b))
+	for i, c := range b {
+		// Equivalent to C's printf("%#02x")
+		if c == 0 {
+			parts[i] = "0"
+		} else {
+			parts[i] = fmt.Sprintf("0x%x", c)
+		}
+	}
+	return fmt.Sprintf("[%db %s]", len(b), strings.Join(parts, " "))
+}
+
+// dumpTimetag formats the timetag as seconds and fraction in hexadecimal.
+func dumpTimetag(t Timetag) string {
+	return fmt.Sprintf("%08x.%08x", t.SecondsSinceEpoch(), t.FractionalSecond())
+}
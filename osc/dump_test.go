@@ -0,0 +1,42 @@
+package osc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMessage_Dump(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		msg  *Message
+		want string
+	}{
+		{"nil", nil, ""},
+		{"addr_only", NewMessage("/foo"), "/foo "},
+		{"numbers", NewMessage("/foo", int32(1), int64(-2), float32(1.5), 2.25), "/foo ihfd 1 -2 1.500000 2.250000"},
+		{"special_floats", NewMessage("/foo", float32(math.NaN()), math.Inf(-1)), "/foo fd nan -inf"},
+		{"string", NewMessage("/foo", "bar baz"), `/foo s "bar baz"`},
+		{"small_blob", NewMessage("/foo", []byte{0, 1, 255}), "/foo b [3b 0 0x1 0xff]"},
+		{"large_blob", NewMessage("/foo", make([]byte, 13)), "/foo b [13 byte blob]"},
+		{"bools_nil", NewMessage("/foo", true, false, nil), "/foo TFN #T #F Nil"},
+		{"timetag", NewMessage("/foo", *NewTimetagFromTimetag(0x0000000100000002)), "/foo t 00000001.00000002"},
+		{"unsupported", NewMessage("/foo", struct{}{}), ""},
+	} {
+		if got := tt.msg.Dump(); got != tt.want {
+			t.Errorf("%s: Dump() = %q, want = %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestBundle_Dump(t *testing.T) {
+	bundle := &Bundle{Timetag: *NewTimetagFromTimetag(1)}
+	bundle.Append(NewMessage("/a", int32(1)))
+	nested := &Bundle{Timetag: *NewTimetagFromTimetag(0x0000000a00000000)}
+	nested.Append(NewMessage("/b", "c"))
+	bundle.Append(nested)
+
+	want := "00000000.00000001 /a i 1\n0000000a.00000000 /b s \"c\""
+	if got := bundle.Dump(); got != want {
+		t.Errorf("Dump() = %q, want = %q", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package osc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsonMessage is the JSON representation of a Message.
+type jsonMessage struct {
+	Address string    `json:"address"`
+	Args    []jsonArg `json:"args"`
+}
+
+// jsonArg is the JSON representation of a Message argument. Type is the OSC
+// type tag of the argument. Blobs are encoded as base64 strings and timetags
+// as RFC 3339 strings.
+type jsonArg struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonBundle is the JSON representation of a Bundle.
+type jsonBundle struct {
+	Timetag  string     `json:"timetag"`
+	Messages []*Message `json:"messages"`
+	Bundles  []*Bundle  `json:"bundles"`
+}
+
+// Verify that Message and Bundle implement the JSON interfaces.
+var (
+	_ json.Marshaler   = (*Message)(nil)
+	_ json.Unmarshaler = (*Message)(nil)
+	_ json.Marshaler   = (*Bundle)(nil)
+	_ json.Unmarshaler = (*Bundle)(nil)
+)
+
+// MarshalJSON implements the json.Marshaler interface. The message is encoded
+// as an object with the address and a list of arguments, each of them with
+// its OSC type tag, e.g. {"address":"/x","args":[{"type":"i","value":1}]}.
+func (msg *Message) MarshalJSON() ([]byte, error) {
+	jm := jsonMessage{Address: msg.Address, Args: make([]jsonArg, 0, len(msg.Arguments))}
+	for _, arg := range msg.Arguments {
+		tag, err := getTypeTag(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{} = arg
+		if c, err := convertInteger(arg); err == nil {
+			v = c
+		}
+		switch t := arg.(type) {
+		case *blobReader:
+			return nil, fmt.Errorf("%w: blob readers can't be encoded as JSON", ErrUnsupportedType)
+		case UnknownArg:
+			return nil, fmt.Errorf("%w: unknown type tag %q can't be encoded as JSON", ErrUnsupportedType, t.Tag)
+		case []interface{}, []float32:
+			return nil, fmt.Errorf("%w: arrays can't be encoded as JSON", ErrUnsupportedType)
+		case Timetag:
+			v = t.Time().Format(time.RFC3339Nano)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		jm.Args = append(jm.Args, jsonArg{Type: string(tag), Value: value})
+	}
+	return json.Marshal(jm)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It reconstructs
+// the arguments with the Go types used for the respective OSC type tags.
+func (msg *Message) UnmarshalJSON(data []byte) error {
+	var jm jsonMessage
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+
+	args := make([]interface{}, 0, len(jm.Args))
+	for _, ja := range jm.Args {
+		arg, err := ja.decode()
+		if err != nil {
+			return err
+		}
+		args = append(args, arg)
+	}
+
+	msg.Address = jm.Address
+	msg.Arguments = args
+	return nil
+}
+
+// decode returns the Go value of the argument.
+func (ja jsonArg) decode() (interface{}, error) {
+	var err error
+	switch ja.Type {
+	case "i":
+		var v int32
+		err = json.Unmarshal(ja.Value, &v)
+		return v, err
+	case "h":
+		var v int64
+		err = json.Unmarshal(ja.Value, &v)
+		return v, err
+	case "f":
+		var v float32
+		err = json.Unmarshal(ja.Value, &v)
+		return v, err
+	case "d":
+		var v float64
+		err = json.Unmarshal(ja.Value, &v)
+		return v, err
+	case "s":
+		var v string
+		err = json.Unmarshal(ja.Value, &v)
+		return v, err
+	case "b":
+		var v []byte
+		err = json.Unmarshal(ja.Value, &v)
+		return v, err
+	case "t":
+		tt, err := parseJSONTimetag(ja.Value)
+		if err != nil {
+			return nil, err
+		}
+		return *tt, nil
+	case "T":
+		return true, nil
+	case "F":
+		return false, nil
+	case "N":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: type tag %q", ErrUnsupportedType, ja.Type)
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface. The bundle is encoded
+// as an object with the timetag as RFC 3339 string and the lists of messages
+// and bundles.
+func (b *Bundle) MarshalJSON() ([]byte, error) {
+	jb := jsonBundle{
+		Timetag:  b.Timetag.Time().Format(time.RFC3339Nano),
+		Messages: b.Messages,
+		Bundles:  b.Bundles,
+	}
+	if jb.Messages == nil {
+		jb.Messages = []*Message{}
+	}
+	if jb.Bundles == nil {
+		jb.Bundles = []*Bundle{}
+	}
+	return json.Marshal(jb)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (b *Bundle) UnmarshalJSON(data []byte) error {
+	var jb struct {
+		Timetag  json.RawMessage `json:"timetag"`
+		Messages []*Message      `json:"messages"`
+		Bundles  []*Bundle       `json:"bundles"`
+	}
+	if err := json.Unmarshal(data, &jb); err != nil {
+		return err
+	}
+
+	tt, err := parseJSONTimetag(jb.Timetag)
+	if err != nil {
+		return err
+	}
+
+	b.Timetag = *tt
+	b.Messages = jb.Messages
+	b.Bundles = jb.Bundles
+	return nil
+}
+
+// parseJSONTimetag parses a timetag encoded as RFC 3339 JSON string.
+func parseJSONTimetag(data []byte) (*Timetag, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return NewTimetag(t), nil
+}
@@ -0,0 +1,106 @@
+package osc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessage_MarshalJSON(t *testing.T) {
+	msg := NewMessage("/x", int32(1), "two")
+	got, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"address":"/x","args":[{"type":"i","value":1},{"type":"s","value":"two"}]}`
+	if string(got) != want {
+		t.Errorf("json.Marshal() = %s, want = %s", got, want)
+	}
+}
+
+func TestMessage_JSONRoundTrip(t *testing.T) {
+	tt := NewTimetag(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC))
+	msg := NewMessage("/a/b",
+		int32(-1), int64(1<<40), float32(1.5), float64(2.25), "str",
+		[]byte{0, 1, 2, 255}, *tt, true, false, nil)
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Message
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Address != msg.Address {
+		t.Errorf("Address = %s, want = %s", got.Address, msg.Address)
+	}
+	if len(got.Arguments) != len(msg.Arguments) {
+		t.Fatalf("got %d arguments, want %d", len(got.Arguments), len(msg.Arguments))
+	}
+	for i, arg := range msg.Arguments {
+		if timetag, ok := arg.(Timetag); ok {
+			if got := got.Arguments[i].(Timetag); got.TimeTag() != timetag.TimeTag() {
+				t.Errorf("argument %d: timetag = %d, want = %d", i, got.TimeTag(), timetag.TimeTag())
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got.Arguments[i], arg) {
+			t.Errorf("argument %d = %#v, want = %#v", i, got.Arguments[i], arg)
+		}
+	}
+}
+
+func TestMessage_UnmarshalJSONErrors(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		data string
+	}{
+		{"unknown_type", `{"address":"/x","args":[{"type":"x","value":1}]}`},
+		{"int_overflow", `{"address":"/x","args":[{"type":"i","value":4294967296}]}`},
+		{"wrong_value", `{"address":"/x","args":[{"type":"s","value":1}]}`},
+		{"bad_timetag", `{"address":"/x","args":[{"type":"t","value":"now"}]}`},
+	} {
+		var msg Message
+		if err := json.Unmarshal([]byte(tt.data), &msg); err == nil {
+			t.Errorf("%s: json.Unmarshal() expected an error", tt.desc)
+		}
+	}
+}
+
+func TestBundle_JSONRoundTrip(t *testing.T) {
+	bundle := NewBundle(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err := bundle.Append(NewMessage("/a", int32(1))); err != nil {
+		t.Fatal(err)
+	}
+	nested := NewBundle(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err := nested.Append(NewMessage("/b", "c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.Append(nested); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Bundle
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := bundle.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotBytes, err := got.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotBytes, want) {
+		t.Errorf("round trip changed the bundle: %s", data)
+	}
+}
@@ -0,0 +1,31 @@
+package osc
+
+// Middleware wraps a message handler to add behavior that is common to many
+// handlers, e.g. logging, metrics or filtering messages by their source. The
+// returned handler usually calls next, unless the message should be dropped.
+//
+// Middleware that needs the network address a message was received from must
+// return an AddrHandler, e.g. an AddrHandlerFunc, and should pass the address
+// on to next if next implements AddrHandler as well.
+type Middleware func(next Handler) Handler
+
+// Chain returns handler wrapped by the given middleware. The first middleware
+// is the outermost one, so it gets each message first. Use Chain to add
+// middleware to a single handler, or the Use method of a StandardDispatcher or
+// ServeMux to add it to all of their handlers.
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// chainHandlers wraps every handler with the middleware.
+func chainHandlers(handlers []Handler, middleware []Middleware) {
+	if len(middleware) == 0 {
+		return
+	}
+	for i, h := range handlers {
+		handlers[i] = Chain(h, middleware...)
+	}
+}
@@ -0,0 +1,106 @@
+package osc
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+// tracing returns middleware that records its name before calling next.
+func tracing(name string, got *[]string) Middleware {
+	return func(next Handler) Handler {
+		return AddrHandlerFunc(func(msg *Message, addr net.Addr) {
+			*got = append(*got, name)
+			callHandlers([]Handler{next}, msg, addr)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var got []string
+	handler := Chain(HandlerFunc(func(msg *Message) {
+		got = append(got, "handler")
+	}), tracing("a", &got), tracing("b", &got))
+	handler.HandleMessage(NewMessage("/a"))
+	if want := []string{"a", "b", "handler"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("called %v, want = %v", got, want)
+	}
+
+	if Chain(nil) != nil {
+		t.Error("Chain() without middleware should return the handler")
+	}
+}
+
+func TestStandardDispatcherUse(t *testing.T) {
+	var got []string
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/a", func(msg *Message) {
+		got = append(got, "/a")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	d.Use(tracing("log", &got))
+
+	// Drop messages from other sources than localhost
+	d.Use(func(next Handler) Handler {
+		return AddrHandlerFunc(func(msg *Message, addr net.Addr) {
+			if ua, ok := addr.(*net.UDPAddr); ok && ua.IP.IsLoopback() {
+				callHandlers([]Handler{next}, msg, addr)
+			}
+		})
+	})
+	if err := d.AddMsgHandler("*", func(msg *Message) {
+		got = append(got, "default")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		ip   string
+		want []string
+	}{
+		{"127.0.0.1", []string{"log", "/a", "log", "default"}},
+		{"192.0.2.1", []string{"log", "log"}},
+	} {
+		got = nil
+		d.DispatchFrom(NewMessage("/a"), &net.UDPAddr{IP: net.ParseIP(tt.ip)})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: called %v, want = %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestServeMuxUse(t *testing.T) {
+	var got []string
+	mux := NewServeMux()
+	sub := NewServeMux()
+	if err := sub.HandleFunc("/freq", func(msg *Message) {
+		got = append(got, "/synth/freq")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Mount("/synth", sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandleFunc("/a", func(msg *Message) {
+		got = append(got, "/a")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mux.Use(tracing("mux", &got))
+	sub.Use(tracing("sub", &got))
+
+	for _, tt := range []struct {
+		addr string
+		want []string
+	}{
+		{"/a", []string{"mux", "/a"}},
+		{"/synth/freq", []string{"mux", "sub", "/synth/freq"}},
+	} {
+		got = nil
+		mux.Dispatch(NewMessage(tt.addr))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: called %v, want = %v", tt.addr, got, tt.want)
+		}
+	}
+}
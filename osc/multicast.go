@@ -0,0 +1,151 @@
+package osc
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// multicastGroup is a multicast group membership of a Server.
+type multicastGroup struct {
+	ip  net.IP
+	ifi *net.Interface
+}
+
+// JoinMulticast joins the multicast group with the given IP address (e.g.
+// "239.0.0.1" or "ff02::114") on the network interface ifi. If ifi is nil,
+// the system assigned multicast interface is used. Call JoinMulticast once per
+// interface to receive the group on multiple interfaces.
+//
+// JoinMulticast may be called before or while the server is serving. Groups
+// joined before are joined as soon as Serve is called. To receive multicast
+// traffic the server must listen on the group's port and not on a unicast
+// address like 127.0.0.1.
+func (s *Server) JoinMulticast(group string, ifi *net.Interface) error {
+	ip, err := parseMulticastGroup(group)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.multicastIndex(ip, ifi) >= 0 {
+		return fmt.Errorf("already joined multicast group %s", group)
+	}
+	for _, c := range s.conns {
+		if err := joinGroup(c, ip, ifi); err != nil {
+			return err
+		}
+	}
+	s.groups = append(s.groups, multicastGroup{ip: ip, ifi: ifi})
+	return nil
+}
+
+// LeaveMulticast leaves the multicast group with the given IP address on the
+// network interface ifi. The group and interface must match a previous call
+// to JoinMulticast.
+func (s *Server) LeaveMulticast(group string, ifi *net.Interface) error {
+	ip, err := parseMulticastGroup(group)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.multicastIndex(ip, ifi)
+	if i < 0 {
+		return fmt.Errorf("not a member of multicast group %s", group)
+	}
+	for _, c := range s.conns {
+		if err := leaveGroup(c, ip, ifi); err != nil {
+			return err
+		}
+	}
+	s.groups = append(s.groups[:i], s.groups[i+1:]...)
+	return nil
+}
+
+// addConn adds a connection the server is serving on and joins all
+// multicast groups on it.
+func (s *Server) addConn(c net.PacketConn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, g := range s.groups {
+		if err := joinGroup(c, g.ip, g.ifi); err != nil {
+			return err
+		}
+	}
+	s.conns = append(s.conns, c)
+	return nil
+}
+
+// removeConn removes a connection added with addConn and stops its
+// advertisements.
+func (s *Server) removeConn(c net.PacketConn) {
+	s.mu.Lock()
+	for i, conn := range s.conns {
+		if conn == c {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			break
+		}
+	}
+	stops := s.adverts[c]
+	delete(s.adverts, c)
+	s.mu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+}
+
+// multicastIndex returns the index of the given group membership or -1.
+func (s *Server) multicastIndex(ip net.IP, ifi *net.Interface) int {
+	for i, g := range s.groups {
+		if !g.ip.Equal(ip) {
+			continue
+		}
+		if (g.ifi == nil && ifi == nil) || (g.ifi != nil && ifi != nil && g.ifi.Index == ifi.Index) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseMulticastGroup parses the given multicast group IP address.
+func parseMulticastGroup(group string) (net.IP, error) {
+	ip := net.ParseIP(group)
+	if ip == nil || !ip.IsMulticast() {
+		return nil, fmt.Errorf("invalid multicast group address: %s", group)
+	}
+	return ip, nil
+}
+
+// joinGroup joins the multicast group ip on the connection c.
+func joinGroup(c net.PacketConn, ip net.IP, ifi *net.Interface) error {
+	if ip.To4() != nil {
+		return ipv4.NewPacketConn(c).JoinGroup(ifi, &net.UDPAddr{IP: ip})
+	}
+	return ipv6.NewPacketConn(c).JoinGroup(ifi, &net.UDPAddr{IP: ip})
+}
+
+// leaveGroup leaves the multicast group ip on the connection c.
+func leaveGroup(c net.PacketConn, ip net.IP, ifi *net.Interface) error {
+	if ip.To4() != nil {
+		return ipv4.NewPacketConn(c).LeaveGroup(ifi, &net.UDPAddr{IP: ip})
+	}
+	return ipv6.NewPacketConn(c).LeaveGroup(ifi, &net.UDPAddr{IP: ip})
+}
+
+// setMulticastInterface sets the interface for outgoing multicast packets to
+// the group ip on the connection c.
+func setMulticastInterface(c net.PacketConn, ip net.IP, ifi *net.Interface) error {
+	if ip.To4() != nil {
+		return ipv4.NewPacketConn(c).SetMulticastInterface(ifi)
+	}
+	return ipv6.NewPacketConn(c).SetMulticastInterface(ifi)
+}
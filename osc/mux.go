@@ -0,0 +1,229 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ServeMux is an OSC message router, similar to the ServeMux of net/http. It
+// holds the handlers registered for OSC addresses and implements the
+// Dispatcher interface, so it can be assigned to the Dispatcher of a Server.
+// Since it doesn't depend on a transport, a single ServeMux can be shared by
+// several servers, e.g. one serving UDP and one serving TCP, and can be
+// tested without any network connection.
+//
+// The address patterns of messages are matched against the handlers of the
+// mux by the same code as in the StandardDispatcher, including path
+// traversal, address normalization and prefix handlers, which are enabled
+// and added with the methods of the same names. Like the StandardDispatcher,
+// the mux counts dispatched and unhandled messages, see Stats.
+//
+// Other ServeMuxes can be mounted under an address prefix to compose
+// routers. The prefix is matched part by part like by Message.Match, so
+// '//' in the address of a message isn't expanded across it; the rest of
+// the address is matched by the mounted mux with its own settings.
+type ServeMux struct {
+	mu             sync.RWMutex
+	handlers       *StandardDispatcher // Handlers of the mux itself
+	defaultHandler Handler
+	mounts         []mountedMux
+	middleware     []Middleware
+}
+
+// mountedMux is a ServeMux mounted under the address prefix given by parts,
+// e.g. ["" "synth"] for "/synth".
+type mountedMux struct {
+	parts []string
+	mux   *ServeMux
+}
+
+// NewServeMux returns a new, empty ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{handlers: NewStandardDispatcher()}
+}
+
+// Handle registers the handler for the given OSC address. The address "*"
+// sets the default handler, which is called for every message. An error is
+// returned if there is already a handler for the address.
+func (m *ServeMux) Handle(addr string, handler Handler) error {
+	if handler == nil {
+		return errors.New("nil handler")
+	}
+	if addr == "*" {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.defaultHandler != nil {
+			return errors.New("default handler exists already")
+		}
+		m.defaultHandler = handler
+		return nil
+	}
+	return m.handlers.Handle(addr, handler)
+}
+
+// HandleFunc registers the handler function for the given OSC address, like
+// Handle.
+func (m *ServeMux) HandleFunc(addr string, handler func(msg *Message)) error {
+	return m.Handle(addr, HandlerFunc(handler))
+}
+
+// AddPrefixHandler adds a handler for all messages whose address starts with
+// the given prefix, like StandardDispatcher.AddPrefixHandler. It is only
+// called if no handler of the mux or of a mounted mux, including the default
+// handlers of mounted muxes, matches the address.
+func (m *ServeMux) AddPrefixHandler(prefix string, handler HandlerFunc) error {
+	return m.handlers.AddPrefixHandler(prefix, handler)
+}
+
+// SetPathTraversal enables or disables the path-traversing wildcard '//' in
+// the address patterns of dispatched messages, like
+// StandardDispatcher.SetPathTraversal.
+func (m *ServeMux) SetPathTraversal(enable bool) {
+	m.handlers.SetPathTraversal(enable)
+}
+
+// SetNormalizeAddresses enables or disables the normalization of addresses,
+// like StandardDispatcher.SetNormalizeAddresses. The prefixes of muxes
+// mounted afterwards are normalized as well.
+func (m *ServeMux) SetNormalizeAddresses(enable bool) {
+	m.handlers.SetNormalizeAddresses(enable)
+}
+
+// Stats returns the message counters of the mux. Messages dispatched to the
+// handlers of mounted muxes are only counted by the mux they are dispatched
+// with. Implements the StatsProvider interface.
+func (m *ServeMux) Stats() Stats {
+	return m.handlers.Stats()
+}
+
+// Mount passes all messages whose address starts with prefix to sub, which
+// matches the rest of the address against its own handlers. If sub is
+// mounted under "/synth", its handler for "/freq" is called for messages to
+// "/synth/freq", which are passed to it unchanged. Wildcards in the address
+// of a message are matched against the prefix as well. Handlers may be added
+// to sub after it has been mounted, but the mounted muxes must not form a
+// cycle.
+func (m *ServeMux) Mount(prefix string, sub *ServeMux) error {
+	if sub == nil {
+		return errors.New("nil ServeMux")
+	}
+	if sub == m {
+		return errors.New("can't mount a ServeMux on itself")
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	m.handlers.mu.RLock()
+	normalize := m.handlers.normalize
+	m.handlers.mu.RUnlock()
+	if normalize {
+		var err error
+		if prefix, err = NormalizeAddress(prefix); err != nil {
+			return err
+		}
+	}
+	if err := checkLeadingSlash(prefix); err != nil {
+		return err
+	}
+	if err := ValidateAddress(prefix); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parts := strings.Split(prefix, "/")
+	for _, mounted := range m.mounts {
+		if strings.Join(mounted.parts, "/") == prefix {
+			return fmt.Errorf("a ServeMux is mounted under %s already", prefix)
+		}
+	}
+	m.mounts = append(m.mounts, mountedMux{parts: parts, mux: sub})
+	return nil
+}
+
+// Use adds middleware that wraps all handlers of the mux, including the
+// default handler, those added before and those of mounted muxes. Middleware
+// added first is the outermost one.
+func (m *ServeMux) Use(middleware ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.middleware = append(m.middleware, middleware...)
+}
+
+// Dispatch dispatches OSC packets. Implements the Dispatcher interface.
+func (m *ServeMux) Dispatch(packet Packet) {
+	m.DispatchFrom(packet, nil)
+}
+
+// DispatchFrom dispatches OSC packets received from the network address addr,
+// which is passed to all handlers implementing AddrHandler. Implements the
+// AddrDispatcher interface.
+func (m *ServeMux) DispatchFrom(packet Packet, addr net.Addr) {
+	dispatchPacket(packet, addr, m.dispatchMessage)
+}
+
+// dispatchNow dispatches the packet right away and returns the number of
+// handlers called. Implements the handlerCounter interface.
+func (m *ServeMux) dispatchNow(packet Packet, addr net.Addr) int {
+	return dispatchPacketNow(packet, addr, m.dispatchMessage)
+}
+
+// dispatchMessage calls all handlers whose address matches the address
+// pattern of msg, including those of mounted muxes, followed by the default
+// handler, and returns their number.
+func (m *ServeMux) dispatchMessage(msg *Message, addr net.Addr) int {
+	// Collect the handlers first, so that they can add handlers themselves
+	handlers := m.match(msg.Address)
+	stats := &m.handlers.stats
+	if len(handlers) == 0 {
+		stats.messagesUnhandled.Add(1)
+		return 0
+	}
+	stats.messagesDispatched.Add(1)
+	callHandlers(handlers, msg, addr)
+	return len(handlers)
+}
+
+// match returns the handlers matching the address pattern, wrapped by the
+// middleware of the mux.
+func (m *ServeMux) match(pattern string) []Handler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d := m.handlers
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	pattern = d.normalizePattern(pattern)
+	handlers := d.matchHandlers(nil, pattern)
+	parts := strings.Split(pattern, "/")
+	for _, mounted := range m.mounts {
+		if rest, ok := trimPrefixParts(parts, mounted.parts); ok {
+			handlers = append(handlers, mounted.mux.match(strings.Join(rest, "/"))...)
+		}
+	}
+	if len(handlers) == 0 {
+		if h := d.prefixHandler(pattern); h != nil {
+			handlers = append(handlers, h)
+		}
+	}
+	if m.defaultHandler != nil {
+		handlers = append(handlers, m.defaultHandler)
+	}
+	chainHandlers(handlers, m.middleware)
+	return handlers
+}
+
+// trimPrefixParts matches the leading address parts against the parts of a
+// prefix and returns the remaining parts as an address of their own.
+func trimPrefixParts(parts, prefix []string) ([]string, bool) {
+	if len(parts) <= len(prefix) {
+		return nil, false
+	}
+	for i, part := range prefix {
+		if !matchPart(parts[i], part) {
+			return nil, false
+		}
+	}
+	return append([]string{""}, parts[len(prefix):]...), true
+}
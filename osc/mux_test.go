@@ -0,0 +1,221 @@
+package osc
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestServeMux(t *testing.T) {
+	var got []string
+	handler := func(name string) func(*Message) {
+		return func(msg *Message) {
+			got = append(got, name)
+		}
+	}
+
+	mux := NewServeMux()
+	for _, addr := range []string{"/a/b", "/a/c", "/x/y"} {
+		if err := mux.HandleFunc(addr, handler(addr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mux.HandleFunc("/a/b", handler("")); err == nil {
+		t.Error("Expected error adding a handler for an existing address")
+	}
+	if err := mux.HandleFunc("/a/*", handler("")); err == nil {
+		t.Error("Expected error adding a handler for a pattern")
+	}
+
+	synth := NewServeMux()
+	if err := synth.HandleFunc("/freq", handler("synth/freq")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Mount("/synth/", synth); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"/synth", "synth", "/", "/a*"} {
+		if err := mux.Mount(prefix, NewServeMux()); err == nil {
+			t.Errorf("Mount(%q) expected an error", prefix)
+		}
+	}
+	if err := mux.Mount("/self", mux); err == nil {
+		t.Error("Expected error mounting a ServeMux on itself")
+	}
+
+	// Handlers may be added after mounting
+	if err := synth.HandleFunc("/gain", handler("synth/gain")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		addr string
+		want []string
+	}{
+		{"/a/b", []string{"/a/b"}},
+		{"/a/*", []string{"/a/b", "/a/c"}},
+		{"/?/?", []string{"/a/b", "/a/c", "/x/y"}},
+		{"/synth/freq", []string{"synth/freq"}},
+		{"/synth/*", []string{"synth/freq", "synth/gain"}},
+		{"/s*/gain", []string{"synth/gain"}},
+		{"/synth", nil},
+		{"/freq", nil},
+	} {
+		got = nil
+		mux.Dispatch(NewMessage(tt.addr))
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dispatched to %v, want = %v", tt.addr, got, tt.want)
+		}
+	}
+
+	// The default handlers of both muxes get mounted messages
+	if err := mux.HandleFunc("*", handler("default")); err != nil {
+		t.Fatal(err)
+	}
+	if err := synth.HandleFunc("*", handler("synth/default")); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	mux.Dispatch(NewMessage("/synth/freq"))
+	if want := []string{"synth/freq", "synth/default", "default"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched to %v, want = %v", got, want)
+	}
+}
+
+func TestServeMuxMatchesLikeStandardDispatcher(t *testing.T) {
+	var got []string
+	handler := func(name string) func(*Message) {
+		return func(msg *Message) {
+			got = append(got, name)
+		}
+	}
+
+	mux := NewServeMux()
+	d := NewStandardDispatcher()
+	for _, addr := range []string{"/a/b", "/a/c", "/x/a/b", "/synth/a/freq"} {
+		if err := mux.HandleFunc(addr, handler(addr)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.HandleFunc(addr, handler(addr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mux.AddPrefixHandler("/synth", handler("prefix")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddPrefixHandler("/synth", handler("prefix")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		pattern   string
+		traverse  bool
+		normalize bool
+		want      []string
+	}{
+		{"/a/*", false, false, []string{"/a/b", "/a/c"}},
+		{"//b", false, false, nil},
+		{"//b", true, false, []string{"/a/b", "/x/a/b"}},
+		{"/a//b", false, false, nil},
+		{"/a//b", false, true, []string{"/a/b"}},
+		{"/synth/a/freq", false, false, []string{"/synth/a/freq"}},
+		{"/synth/b/freq", false, false, []string{"prefix"}},
+		{"/other", false, false, nil},
+	} {
+		var want []string
+		for _, dispatcher := range []interface {
+			Dispatcher
+			SetPathTraversal(bool)
+			SetNormalizeAddresses(bool)
+		}{d, mux} {
+			dispatcher.SetPathTraversal(tt.traverse)
+			dispatcher.SetNormalizeAddresses(tt.normalize)
+			got = nil
+			dispatcher.Dispatch(NewMessage(tt.pattern))
+			sort.Strings(got)
+			if dispatcher == Dispatcher(d) {
+				want = got
+				continue
+			}
+			if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s (traverse = %t, normalize = %t): ServeMux dispatched to %v, StandardDispatcher to %v, want = %v",
+					tt.pattern, tt.traverse, tt.normalize, got, want, tt.want)
+			}
+		}
+	}
+	if got, want := mux.Stats(), d.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want = %+v", got, want)
+	}
+}
+
+func TestServeMuxMountedPrefixHandler(t *testing.T) {
+	var got []string
+	handler := func(name string) func(*Message) {
+		return func(msg *Message) {
+			got = append(got, name)
+		}
+	}
+
+	mux := NewServeMux()
+	synth := NewServeMux()
+	if err := synth.HandleFunc("/a/freq", handler("synth/a/freq")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Mount("/synth", synth); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.AddPrefixHandler("/synth", handler("prefix")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		pattern  string
+		traverse bool
+		want     []string
+	}{
+		{"/synth/a/freq", false, []string{"synth/a/freq"}},
+		{"/synth/b/freq", false, []string{"prefix"}},
+		// '//' isn't expanded across the prefix of a mounted mux, only by
+		// the mounted mux itself
+		{"//a/freq", false, nil},
+		{"/synth//freq", false, []string{"prefix"}},
+		{"/synth//freq", true, []string{"synth/a/freq"}},
+	} {
+		synth.SetPathTraversal(tt.traverse)
+		got = nil
+		mux.Dispatch(NewMessage(tt.pattern))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s (traverse = %t): dispatched to %v, want = %v", tt.pattern, tt.traverse, got, tt.want)
+		}
+	}
+}
+
+func TestServeMuxServer(t *testing.T) {
+	mux := NewServeMux()
+	received := make(chan *Message, 1)
+	if err := mux.HandleFunc("/mux", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, conn := Pipe()
+	defer conn.Close()
+	server := &Server{Dispatcher: mux}
+	if err := client.Send(NewMessage("/mux", int32(1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.ServeOne(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if got, want := msg.Arguments[0], int32(1); got != want {
+			t.Errorf("argument = %v, want = %v", got, want)
+		}
+	default:
+		t.Error("message wasn't dispatched")
+	}
+}
@@ -0,0 +1,226 @@
+package osc
+
+import (
+	"net"
+	"time"
+)
+
+// ServerOption configures a Server created with NewServer.
+type ServerOption func(s *Server)
+
+// NewServer returns a new OSC server listening on addr, configured by the
+// given options. Unless WithDispatcher is given, the server dispatches
+// packets with a new StandardDispatcher. All other settings default to the
+// zero values of the corresponding Server fields, which can still be set
+// directly as well.
+func NewServer(addr string, opts ...ServerOption) *Server {
+	s := &Server{Addr: addr}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+	return s
+}
+
+// WithDispatcher sets the dispatcher of the server, e.g. a ServeMux.
+func WithDispatcher(d Dispatcher) ServerOption {
+	return func(s *Server) { s.Dispatcher = d }
+}
+
+// WithReadTimeout sets the ReadTimeout of the server. By default, reads don't
+// time out.
+func WithReadTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) { s.ReadTimeout = timeout }
+}
+
+// WithLogger sets the Logger of the server. By default, nothing is logged.
+func WithLogger(l Logger) ServerOption {
+	return func(s *Server) { s.Logger = l }
+}
+
+// WithFramedPackets makes the server read multiple size-prefixed packets from
+// one datagram, see Server.FramedPackets. By default, a datagram contains
+// exactly one packet.
+func WithFramedPackets() ServerOption {
+	return func(s *Server) { s.FramedPackets = true }
+}
+
+// WithReadBufferSize sets the ReadBufferSize of the server. By default,
+// DefaultReadBufferSize is used.
+func WithReadBufferSize(size int) ServerOption {
+	return func(s *Server) { s.ReadBufferSize = size }
+}
+
+// WithRateLimit limits the number of packets per second accepted from each
+// source host and sets the size of bursts, see Server.RateLimit and
+// Server.RateBurst. By default, the rate isn't limited.
+func WithRateLimit(rate float64, burst int) ServerOption {
+	return func(s *Server) {
+		s.RateLimit = rate
+		s.RateBurst = burst
+	}
+}
+
+// WithOnDrop sets the function called for packets dropped due to the rate
+// limit, see Server.OnDrop.
+func WithOnDrop(fn func(addr net.Addr, dropped uint64)) ServerOption {
+	return func(s *Server) { s.OnDrop = fn }
+}
+
+// WithOnRawPacket sets the function called with every received datagram
+// before it is parsed, see Server.OnRawPacket.
+func WithOnRawPacket(fn func(data []byte, addr net.Addr)) ServerOption {
+	return func(s *Server) { s.OnRawPacket = fn }
+}
+
+// WithReusePort makes the server listen with SO_REUSEPORT, so that several
+// servers can share a UDP port, see Server.ReusePort.
+func WithReusePort() ServerOption {
+	return func(s *Server) { s.ReusePort = true }
+}
+
+// WithAnswerQueries makes the server answer non-standard address space
+// queries, see Server.AnswerQueries. By default, queries are dispatched like
+// any other message.
+func WithAnswerQueries() ServerOption {
+	return func(s *Server) { s.AnswerQueries = true }
+}
+
+// WithKeepRawBytes makes the server keep the bytes every received message
+// was parsed from, see Server.KeepRawBytes. By default, they aren't kept.
+func WithKeepRawBytes() ServerOption {
+	return func(s *Server) { s.KeepRawBytes = true }
+}
+
+// WithSocketReadBuffer sets the size of the operating system's receive buffer
+// of the connection, see Server.SocketReadBuffer. By default, the size of the
+// operating system is kept.
+func WithSocketReadBuffer(size int) ServerOption {
+	return func(s *Server) { s.SocketReadBuffer = size }
+}
+
+// WithAtomicBundles makes the server dispatch the messages of a bundle back
+// to back, see Server.AtomicBundles. By default, packets are dispatched
+// concurrently.
+func WithAtomicBundles() ServerOption {
+	return func(s *Server) { s.AtomicBundles = true }
+}
+
+// WithDisallowBundles makes the server drop received bundles instead of
+// dispatching them, see Server.DisallowBundles.
+func WithDisallowBundles() ServerOption {
+	return func(s *Server) { s.DisallowBundles = true }
+}
+
+// WithTCPKeepAlive sets the interval between TCP keep-alive probes of
+// accepted connections, see Server.TCPKeepAlive. By default, the default of
+// the net package is kept.
+func WithTCPKeepAlive(period time.Duration) ServerOption {
+	return func(s *Server) { s.TCPKeepAlive = period }
+}
+
+// WithParseOptions sets the options used to parse received packets, see
+// Server.ParseOptions. By default, packets are parsed strictly.
+func WithParseOptions(opts ParseOptions) ServerOption {
+	return func(s *Server) { s.ParseOptions = opts }
+}
+
+// ClientOption configures a Client created with NewClientWithOptions. An
+// option returns an error if its setting can't be applied.
+type ClientOption func(c *Client) error
+
+// NewClientWithOptions returns a new OSC client sending packets to ip:port
+// over UDP, like NewClient, configured by the given options. It returns the
+// error of the first option that can't be applied.
+func NewClientWithOptions(ip string, port int, opts ...ClientOption) (*Client, error) {
+	c := NewClient(ip, port)
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithLocalAddr sets the local address packets are sent from, see
+// Client.SetLocalAddr. By default, the operating system chooses it.
+func WithLocalAddr(ip string, port int) ClientOption {
+	return func(c *Client) error { return c.SetLocalAddr(ip, port) }
+}
+
+// WithBroadcast enables sending to broadcast addresses, see
+// Client.SetBroadcast. It is disabled by default.
+func WithBroadcast() ClientOption {
+	return func(c *Client) error { return c.SetBroadcast(true) }
+}
+
+// WithInterface sets the network interface packets are sent from, see
+// Client.SetInterface. By default, the operating system chooses it.
+func WithInterface(ifi *net.Interface) ClientOption {
+	return func(c *Client) error { return c.SetInterface(ifi) }
+}
+
+// WithWriteBuffer sets the size of the operating system's send buffer, see
+// Client.SetWriteBuffer. By default, the size of the operating system is
+// kept.
+func WithWriteBuffer(size int) ClientOption {
+	return func(c *Client) error {
+		c.SetWriteBuffer(size)
+		return nil
+	}
+}
+
+// WithDSCP sets the Differentiated Services Code Point of sent packets, see
+// Client.SetDSCP. By default, the operating system's setting is kept.
+func WithDSCP(dscp int) ClientOption {
+	return func(c *Client) error { return c.SetDSCP(dscp) }
+}
+
+// WithWriteTimeout sets the timeout for sending a packet, see
+// Client.SetWriteTimeout. By default, sending doesn't time out.
+func WithWriteTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.SetWriteTimeout(timeout)
+		return nil
+	}
+}
+
+// WithTypeTagMode sets the type tags used to encode arguments, see
+// Client.SetTypeTagMode. The default is TypeTagsOSC11.
+func WithTypeTagMode(mode TypeTagMode) ClientOption {
+	return func(c *Client) error {
+		c.SetTypeTagMode(mode)
+		return nil
+	}
+}
+
+// WithFramedSends makes the client prefix every packet with its size, see
+// Client.SetFramedPackets. By default, packets are sent without a prefix.
+func WithFramedSends() ClientOption {
+	return func(c *Client) error {
+		c.SetFramedPackets(true)
+		return nil
+	}
+}
+
+// WithPersistentConn makes the client keep its connection open between
+// sends, see Client.SetPersistent. By default, every packet is sent on a new
+// connection.
+func WithPersistentConn() ClientOption {
+	return func(c *Client) error {
+		c.SetPersistent(true)
+		return nil
+	}
+}
+
+// WithSendRetries makes the client transmit every packet retries more times,
+// waiting interval in between, see Client.SetSendRetries. By default,
+// packets are sent once.
+func WithSendRetries(retries int, interval time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.SetSendRetries(retries, interval)
+		return nil
+	}
+}
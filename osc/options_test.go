@@ -0,0 +1,107 @@
+package osc
+
+import (
+	"log"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	s := NewServer("127.0.0.1:8765")
+	if s.Addr != "127.0.0.1:8765" {
+		t.Errorf("Addr = %q, want = %q", s.Addr, "127.0.0.1:8765")
+	}
+	if _, ok := s.Dispatcher.(*StandardDispatcher); !ok {
+		t.Errorf("Dispatcher = %T, want = *StandardDispatcher", s.Dispatcher)
+	}
+
+	mux := NewServeMux()
+	logger := log.New(nil, "", 0)
+	s = NewServer("",
+		WithDispatcher(mux),
+		WithReadTimeout(time.Second),
+		WithLogger(logger),
+		WithFramedPackets(),
+		WithReadBufferSize(1024),
+		WithRateLimit(100, 10),
+		WithOnDrop(func(net.Addr, uint64) {}),
+		WithOnRawPacket(func([]byte, net.Addr) {}),
+		WithReusePort(),
+		WithAnswerQueries(),
+		WithKeepRawBytes(),
+		WithSocketReadBuffer(1<<20),
+		WithAtomicBundles(),
+		WithDisallowBundles(),
+		WithTCPKeepAlive(30*time.Second),
+		WithParseOptions(ParseOptions{AllowMissingTypeTags: true}),
+	)
+	for _, tt := range []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"Dispatcher", s.Dispatcher, mux},
+		{"ReadTimeout", s.ReadTimeout, time.Second},
+		{"Logger", s.Logger, logger},
+		{"FramedPackets", s.FramedPackets, true},
+		{"ReadBufferSize", s.ReadBufferSize, 1024},
+		{"RateLimit", s.RateLimit, float64(100)},
+		{"RateBurst", s.RateBurst, 10},
+		{"OnDrop", s.OnDrop != nil, true},
+		{"OnRawPacket", s.OnRawPacket != nil, true},
+		{"ReusePort", s.ReusePort, true},
+		{"AnswerQueries", s.AnswerQueries, true},
+		{"KeepRawBytes", s.KeepRawBytes, true},
+		{"SocketReadBuffer", s.SocketReadBuffer, 1 << 20},
+		{"AtomicBundles", s.AtomicBundles, true},
+		{"DisallowBundles", s.DisallowBundles, true},
+		{"TCPKeepAlive", s.TCPKeepAlive, 30 * time.Second},
+		{"ParseOptions", s.ParseOptions, ParseOptions{AllowMissingTypeTags: true}},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want = %v", tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	c, err := NewClientWithOptions("127.0.0.1", 8765,
+		WithLocalAddr("127.0.0.1", 0),
+		WithWriteBuffer(1<<16),
+		WithWriteTimeout(time.Second),
+		WithDSCP(DSCPExpedited),
+		WithTypeTagMode(TypeTagsOSC10),
+		WithSendRetries(2, time.Millisecond),
+		WithFramedSends(),
+		WithPersistentConn(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retries, interval := c.SendRetries()
+	for _, tt := range []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"IP", c.IP(), "127.0.0.1"},
+		{"Port", c.Port(), 8765},
+		{"LocalAddr", c.laddr.String(), "127.0.0.1:0"},
+		{"WriteBuffer", c.WriteBuffer(), 1 << 16},
+		{"WriteTimeout", c.WriteTimeout(), time.Second},
+		{"DSCP", c.DSCP(), DSCPExpedited},
+		{"TypeTagMode", c.TypeTagMode(), TypeTagsOSC10},
+		{"SendRetries", retries, 2},
+		{"SendRetries interval", interval, time.Millisecond},
+		{"FramedPackets", c.FramedPackets(), true},
+		{"Persistent", c.Persistent(), true},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want = %v", tt.field, tt.got, tt.want)
+		}
+	}
+
+	if _, err := NewClientWithOptions("127.0.0.1", 8765, WithLocalAddr("invalid host", 0)); err == nil {
+		t.Error("expected an error for an invalid local address")
+	}
+}
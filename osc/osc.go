@@ -5,14 +5,21 @@ package osc
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"reflect"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -21,16 +28,127 @@ const (
 	bundleTagString       = "#bundle"
 )
 
-// Packet is the interface for Message and Bundle.
+// DefaultReadBufferSize is the default size of the Server's read buffer,
+// which is large enough for any UDP datagram.
+const DefaultReadBufferSize = 65535
+
+// ErrPacketTruncated is returned by the Server if a received datagram doesn't
+// fit into its read buffer, see Server.ReadBufferSize. Unlike ErrShortPacket,
+// it doesn't mean that the packet is malformed: the sender may have sent a
+// valid packet, which was cut off by the receiving side and isn't parsed at
+// all. It doesn't wrap any of the parse errors below.
+var ErrPacketTruncated = errors.New("OSC packet truncated: datagram exceeds read buffer")
+
+// Errors returned when parsing and marshaling OSC packets. Most errors wrap
+// one of them together with details, so use errors.Is to check for them.
+var (
+	// ErrEmptyPacket is returned if a packet doesn't contain any data.
+	ErrEmptyPacket = errors.New("OSC packet is empty")
+
+	// ErrShortPacket is wrapped by the errors returned if a packet ends
+	// before all of its content has been read, e.g. within an argument, so
+	// the packet as received is incomplete or its sizes are wrong.
+	ErrShortPacket = errors.New("OSC packet too short")
+
+	// ErrPacketTooShort is returned for the special case of a packet that
+	// is too short to contain even an OSC address or bundle tag, i.e. less
+	// than 4 bytes long. It wraps ErrShortPacket, so checking for
+	// ErrShortPacket covers it.
+	ErrPacketTooShort = fmt.Errorf("%w for an OSC address or bundle tag", ErrShortPacket)
+
+	// ErrInvalidPacket is wrapped by the errors returned for malformed
+	// packets, e.g. if a packet or bundle element neither starts with '/'
+	// (message) nor with '#' (bundle) or if its type tag string is invalid.
+	ErrInvalidPacket = errors.New("invalid OSC packet")
+
+	// ErrBundleTooDeep is returned for bundles that are nested deeper than
+	// the maximum depth, see ParseOptions.MaxBundleDepth. It wraps
+	// ErrInvalidPacket.
+	ErrBundleTooDeep = fmt.Errorf("%w: bundles nested too deeply", ErrInvalidPacket)
+
+	// ErrTooManyArguments is returned for messages with more arguments than
+	// allowed, see ParseOptions.MaxArguments. It wraps ErrInvalidPacket.
+	ErrTooManyArguments = fmt.Errorf("%w: too many arguments", ErrInvalidPacket)
+
+	// ErrUnsupportedType is wrapped by the errors returned for arguments
+	// that can't be encoded, e.g. of Go types without an OSC type tag.
+	ErrUnsupportedType = errors.New("unsupported argument type")
+)
+
+// errSockoptUnsupported is returned on platforms without socket options.
+var errSockoptUnsupported = errors.New("socket options are not supported on this platform")
+
+// Packet is the interface for Message and Bundle. It can't be implemented by
+// other types, so that functions taking a Packet, like Bundle.Append, only
+// need to handle these two. This is an incompatible change to earlier
+// versions, where any encoding.BinaryMarshaler was a Packet: types of other
+// packages implementing Packet must encode themselves with MarshalBinary
+// and be sent with Client.SendRaw instead, or be converted to a Message.
 type Packet interface {
 	encoding.BinaryMarshaler
+	isPacket()
+}
+
+// IsMessage reports whether p is a non-nil *Message.
+func IsMessage(p Packet) bool {
+	_, ok := AsMessage(p)
+	return ok
+}
+
+// IsBundle reports whether p is a non-nil *Bundle.
+func IsBundle(p Packet) bool {
+	_, ok := AsBundle(p)
+	return ok
+}
+
+// AsMessage returns p as *Message and reports whether it is a non-nil
+// message.
+func AsMessage(p Packet) (*Message, bool) {
+	msg, ok := p.(*Message)
+	return msg, ok && msg != nil
+}
+
+// AsBundle returns p as *Bundle and reports whether it is a non-nil bundle.
+func AsBundle(p Packet) (*Bundle, bool) {
+	b, ok := p.(*Bundle)
+	return b, ok && b != nil
 }
 
 // Message represents a single OSC message. An OSC message consists of an OSC
 // address pattern and zero or more arguments.
+//
+// The following Go types are supported as arguments (OSC type tag in
+// parentheses): int32 ('i'), int64 ('h'), float32 ('f'), float64 ('d'),
+// string ('s'), []byte and Blob ('b'), Timetag ('t'), true ('T'), false
+// ('F') and nil ('N'). An []interface{} argument is an OSC array, whose
+// elements are enclosed in '[' and ']' in the type tag string and may be
+// arrays again. A []float32 argument is an OSC array of float32 elements,
+// which is encoded faster than the equivalent []interface{}.
+//
+// As a convenience, arguments of the other Go integer types are encoded as
+// follows: int8, int16, uint8 and uint16 as OSC int32 ('i'); int, uint and
+// uint32 as OSC int32 ('i') as well, but values that don't fit into an int32
+// cause an error; uint64 as OSC int64 ('h'), with an error for values that
+// don't fit into an int64. Unsigned values are never reinterpreted bit for
+// bit. Since such arguments are decoded as int32 or int64, use these types
+// explicitly to receive the same values as sent.
+//
+// Arguments of received messages are decoded as the types listed first,
+// i.e. blobs ('b') as []byte and not as Blob, and arrays as []interface{};
+// use AsFloat32Slice to get an array of floats as []float32. Arguments with
+// other type tags are decoded as UnknownArg.
+//
+// The type tag string is cached when it is first computed, e.g. by
+// MarshalBinary, so that a message sent many times doesn't build it again.
+// The cache is checked against the arguments before it is used, so
+// Arguments may still be modified in place between sends.
 type Message struct {
 	Address   string
 	Arguments []interface{}
+
+	raw       []byte                 // Encoded message, if kept when parsing
+	tags      atomic.Pointer[string] // Cached type tag string
+	truncated bool                   // Arguments were missing when parsing
 }
 
 // Verify that Messages implements the Packet interface.
@@ -52,18 +170,179 @@ var _ Packet = (*Bundle)(nil)
 // Client enables you to send OSC packets. It sends OSC messages and bundles to
 // the given IP address and port.
 type Client struct {
-	ip    string
-	port  int
-	laddr *net.UDPAddr
+	ip        string
+	port      int
+	laddr     *net.UDPAddr
+	broadcast bool
+	ifi       *net.Interface
+	tagMode   TypeTagMode
+	retries   int
+	interval  time.Duration
+	unixPath  string    // Unix domain socket path, if set
+	pipe      *pipeConn // In-memory connection, if set
+	sndBuf    int       // Socket send buffer size, if set
+	dscp      int       // DSCP of sent packets, if set
+	wtimeout  time.Duration
+	framed    bool // Packets are prefixed with their size
+
+	mu         sync.Mutex // Guards persistent and conn
+	persistent bool
+	conn       net.Conn // Connection kept open if persistent
 }
 
+// TypeTagMode selects the type tags used to encode arguments.
+type TypeTagMode int
+
+const (
+	// TypeTagsOSC11 encodes booleans and nil with the OSC 1.1 type tags 'T',
+	// 'F' and 'N', which have no argument data. This is the default.
+	TypeTagsOSC11 TypeTagMode = iota
+
+	// TypeTagsOSC10 encodes booleans as int32 arguments with the values 0
+	// and 1, for receivers that only understand the OSC 1.0 standard type
+	// tags. Since the receiver can't tell these arguments from integers,
+	// they are decoded as int32. Nil arguments can't be encoded in this
+	// mode and cause an error.
+	TypeTagsOSC10
+)
+
 // Server represents an OSC server. The server listens on Address and Port for
 // incoming OSC packets and bundles.
 type Server struct {
 	Addr        string
 	Dispatcher  Dispatcher
 	ReadTimeout time.Duration
-	close       func() error
+	Logger      Logger // Logger for diagnostics. If nil, nothing is logged.
+
+	// FramedPackets enables reading multiple OSC packets from one datagram.
+	// If set, every datagram must consist of one or more packets, each
+	// prefixed with its size as a big-endian int32, like in the OSC 1.0
+	// stream transport. The further packets of a datagram are returned by
+	// the next reads from the same connection. By default a datagram
+	// contains exactly one packet without a size prefix.
+	FramedPackets bool
+
+	// ReadBufferSize is the size of the buffer that datagrams are read into,
+	// which limits the size of received packets. Larger datagrams are
+	// rejected with ErrPacketTruncated by ReceivePacket and counted as parse
+	// errors and dropped by Serve. If zero, DefaultReadBufferSize is used.
+	ReadBufferSize int
+
+	// RateLimit limits the number of packets per second that are accepted
+	// from each source host. Packets exceeding the limit are dropped before
+	// they are dispatched. A bundle counts as a single packet. If zero, the
+	// rate isn't limited. The limit can't be changed once the server has
+	// received packets.
+	RateLimit float64
+
+	// RateBurst is the number of packets a source host may send at once
+	// before RateLimit applies. Values below one are treated as one.
+	RateBurst int
+
+	// OnDrop, if set, is called for every packet that is dropped because
+	// its source exceeds the rate limit, with the number of packets dropped
+	// from the source so far.
+	OnDrop func(addr net.Addr, dropped uint64)
+
+	// OnRawPacket, if set, is called with the bytes of every received
+	// datagram and its source address before the datagram is parsed, also
+	// for datagrams that fail to parse or are truncated. For connections
+	// served with ServeTCP or ServeConn, it is called with the bytes of
+	// every packet without its size prefix. Use it to log or forward the
+	// received traffic. The bytes must not be modified, but may be
+	// retained. It is called from the goroutine reading from the
+	// connection, so it should return quickly.
+	OnRawPacket func(data []byte, addr net.Addr)
+
+	// ReusePort makes ListenAndServe and ListenAndServeAll set the socket
+	// option SO_REUSEPORT, so that several servers, e.g. one per CPU core,
+	// can listen on the same UDP port and the operating system distributes
+	// the received datagrams among them. It is supported on Linux, macOS,
+	// the BSDs and AIX; on Linux, datagrams from the same source are always
+	// delivered to the same server. On other platforms, including Windows,
+	// the option is ignored with a message to the Logger, so listening on a
+	// port already in use fails.
+	ReusePort bool
+
+	// AnswerQueries makes the server answer address space queries, a
+	// non-standard extension of OSC for discovering the addresses a server
+	// handles. A query is a message whose address is the address of a node
+	// followed by "/?", e.g. "/synth/?", or "/?" for the root node. The
+	// server replies to its source with a message to QueryReplyAddress,
+	// whose first argument is the query address, followed by the addresses
+	// of all handlers under the node as strings. Queries are answered only
+	// if they aren't contained in a bundle and the Dispatcher has an
+	// Addresses method like the StandardDispatcher; other queries are
+	// dispatched like any other message. Since '?' is also the wildcard for
+	// a single character, address patterns ending in "/?", e.g. "/mixer/?"
+	// for "/mixer/1" to "/mixer/9", are answered as queries as well and
+	// never reach the handlers while AnswerQueries is set.
+	AnswerQueries bool
+
+	// KeepRawBytes keeps the bytes every received message was parsed from,
+	// which are returned by Message.Raw.
+	KeepRawBytes bool
+
+	// SocketReadBuffer sets the size of the operating system's receive
+	// buffer of the connection in bytes, like net.UDPConn.SetReadBuffer.
+	// Packets that arrive while the buffer is full are dropped by the
+	// operating system, so increase it for bursts of packets at high rates.
+	// Typical values are between 256 KiB and a few MiB; the operating system
+	// may clamp the size to a system-wide limit, e.g. net.core.rmem_max on
+	// Linux. The size is set when Serve starts. If zero, the default size is
+	// kept.
+	SocketReadBuffer int
+
+	// AtomicBundles makes the server dispatch the messages of a bundle back
+	// to back, without dispatching messages of other packets in between. By
+	// default, packets are dispatched concurrently and the messages of a
+	// bundle are dispatched in order, but may be interleaved with other
+	// packets. The messages of a bundle are still handled one after another,
+	// so true atomicity isn't guaranteed. Nested bundles with a later time
+	// tag are dispatched separately.
+	AtomicBundles bool
+
+	// DisallowBundles makes the server drop received bundles instead of
+	// dispatching them, for endpoints that only expect single messages.
+	// Dropped bundles are logged to the Logger and counted in
+	// Stats.BundlesRejected.
+	DisallowBundles bool
+
+	// TCPKeepAlive sets the interval between TCP keep-alive probes of the
+	// connections accepted by ServeTCP, which detect dead peers of
+	// long-lived connections. A negative value disables keep-alive probes.
+	// If zero, the default of the net package is kept, which currently
+	// enables probes every 15 seconds on most platforms.
+	TCPKeepAlive time.Duration
+
+	// ParseOptions configures how received packets are parsed. The zero
+	// value parses strictly, like ParsePacket.
+	ParseOptions ParseOptions
+
+	close func() error
+
+	dispatchMu sync.RWMutex // Guards bundle dispatching if AtomicBundles is set
+
+	mu      sync.Mutex
+	conns   []net.PacketConn            // Connections being served
+	adverts map[net.PacketConn][]func() // Stops advertisements of connections
+	groups  []multicastGroup
+	pending map[PacketReader][]pendingPacket // Packets read but not yet returned
+	limiter *rateLimiter
+	stats   serverStats
+}
+
+// pendingPacket is a packet that has been read, but not been returned yet.
+type pendingPacket struct {
+	packet Packet
+	addr   net.Addr
+}
+
+// Logger is the interface used by the Server to report diagnostics. It is
+// satisfied by *log.Logger and by the sugared loggers of most structured
+// logging packages.
+type Logger interface {
+	Printf(format string, v ...interface{})
 }
 
 // Timetag represents an OSC Time Tag.
@@ -84,6 +363,14 @@ type Dispatcher interface {
 	Dispatch(packet Packet)
 }
 
+// AddrDispatcher is implemented by dispatchers that need the network address
+// packets were received from. The Server calls DispatchFrom instead of
+// Dispatch for dispatchers implementing it.
+type AddrDispatcher interface {
+	Dispatcher
+	DispatchFrom(packet Packet, addr net.Addr)
+}
+
 // Handler is an interface for message handlers. Every handler implementation
 // for an OSC message must implement this interface.
 type Handler interface {
@@ -100,282 +387,1060 @@ func (f HandlerFunc) HandleMessage(msg *Message) {
 	f(msg)
 }
 
+// AddrHandler is implemented by message handlers that need the network
+// address messages were received from. For messages contained in bundles, it
+// is the address the bundle was received from.
+type AddrHandler interface {
+	HandleMessageFrom(msg *Message, addr net.Addr)
+}
+
+// AddrHandlerFunc implements the Handler and the AddrHandler interface. Type
+// definition for an OSC handler function that gets the source address of the
+// message.
+type AddrHandlerFunc func(msg *Message, addr net.Addr)
+
+// HandleMessage calls itself with the given OSC Message and a nil address.
+// Implements the Handler interface.
+func (f AddrHandlerFunc) HandleMessage(msg *Message) {
+	f(msg, nil)
+}
+
+// HandleMessageFrom calls itself with the given OSC Message and address.
+// Implements the AddrHandler interface.
+func (f AddrHandlerFunc) HandleMessageFrom(msg *Message, addr net.Addr) {
+	f(msg, addr)
+}
+
 ////
 // StandardDispatcher
 ////
 
 // StandardDispatcher is a dispatcher for OSC packets. It handles the dispatching of
-// received OSC packets to Handlers for their given address.
+// received OSC packets to Handlers for their given address. The handlers are
+// stored in a tree keyed on the parts of their addresses, so the lookup time
+// depends on the depth of an address and not on the number of handlers.
+//
+// Handlers may be added and removed while packets are dispatched, e.g. by a
+// running Server. The handlers are guarded by a read-write mutex that is only
+// read-locked during dispatching.
 type StandardDispatcher struct {
-	handlers       map[string]Handler
+	mu             sync.RWMutex
+	handlers       *addressNode
+	prefixHandlers map[string]Handler
 	defaultHandler Handler
+	pathTraversal  bool
+	normalize      bool
+	middleware     []Middleware
+	stats          dispatcherStats
 }
 
 // NewStandardDispatcher returns an StandardDispatcher.
 func NewStandardDispatcher() *StandardDispatcher {
-	return &StandardDispatcher{handlers: make(map[string]Handler)}
+	return &StandardDispatcher{handlers: newAddressNode()}
 }
 
-// AddMsgHandler adds a new message handler for the given OSC address.
+// AddMsgHandler adds a new message handler for the given OSC address. The
+// address "*" sets the default handler, which is called for every message.
+// An error is returned if there is already a handler for the address, use
+// SetMsgHandler to replace it.
 func (s *StandardDispatcher) AddMsgHandler(addr string, handler HandlerFunc) error {
+	return s.addMsgHandler(addr, handler, false)
+}
+
+// AddMsgHandlerFrom adds a new message handler for the given OSC address,
+// which gets the network address each message was received from. Otherwise
+// it behaves like AddMsgHandler.
+func (s *StandardDispatcher) AddMsgHandlerFrom(addr string, handler AddrHandlerFunc) error {
+	return s.addMsgHandler(addr, handler, false)
+}
+
+// Handle adds a message handler for the given OSC address, like
+// AddMsgHandler. Handlers implementing AddrHandler get the network address
+// each message was received from.
+func (s *StandardDispatcher) Handle(addr string, handler Handler) error {
+	if handler == nil {
+		return errors.New("nil handler")
+	}
+	return s.addMsgHandler(addr, handler, false)
+}
+
+// HandleFunc adds a message handler function for the given OSC address, like
+// AddMsgHandler.
+func (s *StandardDispatcher) HandleFunc(addr string, handler func(msg *Message)) error {
+	return s.addMsgHandler(addr, HandlerFunc(handler), false)
+}
+
+// SetMsgHandler sets the message handler for the given OSC address, replacing
+// the current handler for the address if there is one.
+func (s *StandardDispatcher) SetMsgHandler(addr string, handler HandlerFunc) error {
+	return s.addMsgHandler(addr, handler, true)
+}
+
+func (s *StandardDispatcher) addMsgHandler(addr string, handler Handler, replace bool) error {
 	if addr == "*" {
+		s.mu.Lock()
 		s.defaultHandler = handler
+		s.mu.Unlock()
 		return nil
 	}
-	for _, chr := range "*?,[]{}# " {
-		if strings.Contains(addr, fmt.Sprintf("%c", chr)) {
-			return errors.New("OSC Address string may not contain any characters in \"*?,[]{}#")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.normalize {
+		var err error
+		if addr, err = NormalizeAddress(addr); err != nil {
+			return err
 		}
 	}
-
-	if addressExists(addr, s.handlers) {
+	if err := ValidateAddress(addr); err != nil {
+		return err
+	}
+	if replace {
+		s.handlers.remove(addr)
+	}
+	if !s.handlers.insert(addr, handler) {
 		return errors.New("OSC address exists already")
 	}
+	return nil
+}
+
+// AddPrefixHandler adds a message handler for all messages whose address
+// starts with the given prefix, e.g. "/synth" for "/synth/a/freq". Messages
+// are only passed to the handler of the longest matching prefix, and only if
+// no handler matches their address exactly or by a wildcard. The default
+// handler is called in any case. The prefix is compared with the address of
+// a message part by part, wildcards in the address aren't expanded. The
+// prefix "/" catches all messages that aren't handled otherwise. If
+// normalization is enabled, see SetNormalizeAddresses, the prefix is
+// normalized like the addresses of message handlers.
+func (s *StandardDispatcher) AddPrefixHandler(prefix string, handler HandlerFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The root prefix is stored as "", the prefix of every address once its
+	// last part has been cut off
+	if prefix == "/" {
+		prefix = ""
+	} else {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if s.normalize {
+			var err error
+			if prefix, err = NormalizeAddress(prefix); err != nil {
+				return err
+			}
+		}
+		if err := checkLeadingSlash(prefix); err != nil {
+			return err
+		}
+		if err := ValidateAddress(prefix); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := s.prefixHandlers[prefix]; ok {
+		return errors.New("OSC address prefix exists already")
+	}
+	if s.prefixHandlers == nil {
+		s.prefixHandlers = make(map[string]Handler)
+	}
+	s.prefixHandlers[prefix] = handler
+	return nil
+}
+
+// prefixHandler returns the handler of the longest prefix of addr or nil if
+// there is none.
+func (s *StandardDispatcher) prefixHandler(addr string) Handler {
+	for p := addr; len(s.prefixHandlers) > 0; {
+		if h, ok := s.prefixHandlers[p]; ok {
+			return h
+		}
+		i := strings.LastIndexByte(p, '/')
+		if i < 0 {
+			return nil
+		}
+		p = p[:i]
+	}
+	return nil
+}
+
+// RemoveMsgHandler removes the message handler for the given OSC address. The
+// address "*" removes the default handler. An error is returned if there is
+// no handler for the address. If normalization is enabled, see
+// SetNormalizeAddresses, the address is normalized first, so a handler can be
+// removed with the same address it was added with. Messages that are being
+// dispatched while the handler is removed may still be passed to it.
+func (s *StandardDispatcher) RemoveMsgHandler(addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	s.handlers[addr] = handler
+	if addr == "*" {
+		if s.defaultHandler == nil {
+			return errors.New("no default handler")
+		}
+		s.defaultHandler = nil
+		return nil
+	}
+	if s.normalize {
+		var err error
+		if addr, err = NormalizeAddress(addr); err != nil {
+			return err
+		}
+	}
+	if !s.handlers.remove(addr) {
+		return fmt.Errorf("no handler for OSC address %s", addr)
+	}
 	return nil
 }
 
 // Dispatch dispatches OSC packets. Implements the Dispatcher interface.
 func (s *StandardDispatcher) Dispatch(packet Packet) {
+	s.DispatchFrom(packet, nil)
+}
+
+// DispatchFrom dispatches OSC packets received from the network address addr.
+// The address is passed to all handlers implementing AddrHandler, including
+// the handlers of messages in nested bundles. Implements the AddrDispatcher
+// interface.
+func (s *StandardDispatcher) DispatchFrom(packet Packet, addr net.Addr) {
+	dispatchPacket(packet, addr, s.dispatchMessage)
+}
+
+// dispatchPacket calls dispatchMessage for the packet if it is a message and
+// for all messages of it if it is a bundle, including those of nested
+// bundles. Bundles that aren't due yet are dispatched once their time tag has
+// been reached.
+func dispatchPacket(packet Packet, addr net.Addr, dispatchMessage func(*Message, net.Addr) int) {
 	switch p := packet.(type) {
 	default:
 		return
 
 	case *Message:
-		for addr, handler := range s.handlers {
-			if p.Match(addr) {
-				handler.HandleMessage(p)
-			}
-		}
-		if s.defaultHandler != nil {
-			s.defaultHandler.HandleMessage(p)
-		}
+		dispatchMessage(p, addr)
 
 	case *Bundle:
-		timer := time.NewTimer(p.Timetag.ExpiresIn())
+		// Bundles that are due immediately are dispatched right away, all
+		// others once their time tag has been reached
+		if p.Timetag.IsImmediate() || p.Timetag.ExpiresIn() == 0 {
+			dispatchBundle(p, addr, dispatchMessage)
+			return
+		}
 
+		timer := time.NewTimer(p.Timetag.ExpiresIn())
 		go func() {
 			<-timer.C
-			for _, message := range p.Messages {
-				for address, handler := range s.handlers {
-					if message.Match(address) {
-						handler.HandleMessage(message)
-					}
-				}
-				if s.defaultHandler != nil {
-					s.defaultHandler.HandleMessage(message)
-				}
-			}
-
-			// Process all bundles
-			for _, b := range p.Bundles {
-				s.Dispatch(b)
-			}
+			dispatchBundle(p, addr, dispatchMessage)
 		}()
 	}
 }
 
+// dispatchBundle dispatches all elements of the bundle.
+func dispatchBundle(b *Bundle, addr net.Addr, dispatchMessage func(*Message, net.Addr) int) {
+	for _, message := range b.Messages {
+		dispatchMessage(message, addr)
+	}
+
+	// Process all bundles
+	for _, nested := range b.Bundles {
+		dispatchPacket(nested, addr, dispatchMessage)
+	}
+}
+
+// dispatchPacketNow calls dispatchMessage for all messages of the packet in
+// the same order as dispatchPacket, but right away, regardless of the time
+// tags of bundles. It returns the number of handlers called.
+func dispatchPacketNow(packet Packet, addr net.Addr, dispatchMessage func(*Message, net.Addr) int) int {
+	n := 0
+	walkMessages(packet, nil, func(msg *Message, _ *Bundle) {
+		n += dispatchMessage(msg, addr)
+	})
+	return n
+}
+
+// handlerCounter is implemented by dispatchers that can dispatch a packet
+// right away and report the number of handlers called, see
+// Server.DispatchPacket.
+type handlerCounter interface {
+	dispatchNow(packet Packet, addr net.Addr) int
+}
+
+// dispatchNow dispatches the packet right away and returns the number of
+// handlers called. Implements the handlerCounter interface.
+func (s *StandardDispatcher) dispatchNow(packet Packet, addr net.Addr) int {
+	return dispatchPacketNow(packet, addr, s.dispatchMessage)
+}
+
+// dispatchMessage calls all handlers whose address matches the address
+// pattern of msg, followed by the default handler, and returns their number.
+func (s *StandardDispatcher) dispatchMessage(msg *Message, addr net.Addr) int {
+	// Collect the handlers first, so that they can add handlers themselves
+	s.mu.RLock()
+	pattern := s.normalizePattern(msg.Address)
+	handlers := s.matchHandlers(nil, pattern)
+	if len(handlers) == 0 {
+		if h := s.prefixHandler(pattern); h != nil {
+			handlers = append(handlers, h)
+		}
+	}
+	if s.defaultHandler != nil {
+		handlers = append(handlers, s.defaultHandler)
+	}
+	chainHandlers(handlers, s.middleware)
+	s.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		s.stats.messagesUnhandled.Add(1)
+		return 0
+	}
+	s.stats.messagesDispatched.Add(1)
+	callHandlers(handlers, msg, addr)
+	return len(handlers)
+}
+
+// normalizePattern returns the address pattern normalized if normalization
+// is enabled and path traversal isn't, see SetNormalizeAddresses. s.mu must
+// be held.
+func (s *StandardDispatcher) normalizePattern(pattern string) string {
+	if s.normalize && !s.pathTraversal {
+		if normalized, err := NormalizeAddress(pattern); err == nil {
+			return normalized
+		}
+	}
+	return pattern
+}
+
+// matchHandlers appends the handlers whose address matches the address
+// pattern to handlers and returns the extended slice. Prefix handlers and the
+// default handler aren't included. s.mu must be held.
+func (s *StandardDispatcher) matchHandlers(handlers []Handler, pattern string) []Handler {
+	s.handlers.match(pattern, s.pathTraversal, func(h Handler) {
+		handlers = append(handlers, h)
+	})
+	return handlers
+}
+
+// callHandlers passes msg to all handlers, together with addr to those that
+// implement AddrHandler.
+func callHandlers(handlers []Handler, msg *Message, addr net.Addr) {
+	for _, h := range handlers {
+		if ah, ok := h.(AddrHandler); ok {
+			ah.HandleMessageFrom(msg, addr)
+		} else {
+			h.HandleMessage(msg)
+		}
+	}
+}
+
+// Use adds middleware that wraps all handlers of the dispatcher, including
+// the default handler and those added before. Middleware added first is the
+// outermost one.
+func (s *StandardDispatcher) Use(middleware ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// SetPathTraversal enables or disables the path-traversing wildcard '//' in
+// the address patterns of dispatched messages. If enabled, '//' matches any
+// number of address parts, e.g. "/synth//freq" matches "/synth/a/freq" as
+// well as "/synth/a/b/freq". This is an extension to OSC 1.0 and disabled by
+// default.
+func (s *StandardDispatcher) SetPathTraversal(enable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pathTraversal = enable
+}
+
+// SetNormalizeAddresses enables or disables the normalization of addresses
+// with NormalizeAddress. If enabled, the addresses of handlers and the
+// prefixes of prefix handlers added or removed afterwards are normalized,
+// and adding a message handler for an address with a trailing slash fails. The address patterns of dispatched messages are
+// normalized before they are matched, so "/a//b" matches the handler for
+// "/a/b", while patterns that can't be normalized are matched unchanged.
+// If path traversal is enabled, see SetPathTraversal, '//' keeps its meaning
+// and the patterns of messages aren't normalized. Normalization is disabled
+// by default.
+func (s *StandardDispatcher) SetNormalizeAddresses(enable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.normalize = enable
+}
+
+// Addresses returns the sorted addresses of all registered message handlers,
+// excluding the default handler. It is safe to call Addresses while packets
+// are dispatched.
+func (s *StandardDispatcher) Addresses() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	addrs := s.handlers.addresses()
+	sort.Strings(addrs)
+	return addrs
+}
+
 ////
 // Message
 ////
 
 // NewMessage returns a new Message. The address parameter is the OSC address.
+// The address isn't validated, use ValidatePattern or NewMessageWithArgs to
+// check it. MarshalBinary fails for addresses that don't start with '/'.
 func NewMessage(addr string, args ...interface{}) *Message {
 	return &Message{Address: addr, Arguments: args}
 }
 
-// Append appends the given arguments to the arguments list.
+// NewMessageWithArgs returns a new Message with the given OSC address and
+// arguments. Unlike NewMessage, the address is checked with ValidatePattern
+// and the arguments are checked like in AppendChecked. An error is returned
+// for an invalid address or the first unsupported argument.
+func NewMessageWithArgs(addr string, args ...interface{}) (*Message, error) {
+	if err := ValidatePattern(addr); err != nil {
+		return nil, err
+	}
+	msg := NewMessage(addr)
+	if err := msg.AppendChecked(args...); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Append appends the given arguments to the arguments list. The arguments
+// aren't checked; arguments of unsupported types cause MarshalBinary to fail.
+// Use AppendChecked to detect them early.
 func (msg *Message) Append(args ...interface{}) {
 	msg.Arguments = append(msg.Arguments, args...)
+	msg.tags.Store(nil)
 }
 
-// Equals returns true if the given OSC Message `m` is equal to the current OSC
-// Message. It checks if the OSC address and the arguments are equal. Returns
-// true if the current object and `m` are equal.
-func (msg *Message) Equals(m *Message) bool {
-	return reflect.DeepEqual(msg, m)
+// AppendTime appends the time t as a time tag argument with the type tag
+// 't'. It is equivalent to Append(*NewTimetag(t)). Since time tags have a
+// resolution finer than a nanosecond, the time of the parsed argument equals
+// t, except for its location and monotonic clock reading.
+func (msg *Message) AppendTime(t time.Time) {
+	msg.Append(*NewTimetag(t))
 }
 
-// Clear clears the OSC address and all arguments.
-func (msg *Message) Clear() {
-	msg.Address = ""
-	msg.ClearData()
+// AppendFloat32Slice appends v as a single OSC array argument of float32
+// elements, e.g. the samples of a waveform. Unlike appending v as
+// []interface{}, the elements aren't boxed and are encoded in one pass. v is
+// kept by the message and must not be modified until it is marshaled.
+func (msg *Message) AppendFloat32Slice(v []float32) {
+	msg.Append(v)
 }
 
-// ClearData removes all arguments from the OSC Message.
-func (msg *Message) ClearData() {
-	msg.Arguments = msg.Arguments[len(msg.Arguments):]
+// Blob is an OSC blob argument ('b'). It is encoded exactly like a []byte
+// argument, but makes the intent explicit in code that uses byte slices for
+// other purposes as well. Received blobs are decoded as []byte.
+type Blob []byte
+
+// Argument is the set of Go types that are supported as OSC arguments. It
+// can't include nil, use Append to add an OSC Nil argument.
+type Argument interface {
+	int32 | int64 | float32 | float64 | string | []byte | Blob | bool | Timetag
 }
 
-// Match returns true, if the OSC address pattern of the OSC Message matches the given
-// address. The match is case sensitive!
-func (msg *Message) Match(addr string) bool {
-	exp := getRegEx(msg.Address)
-	if exp.MatchString(addr) {
-		return true
-	}
-	return false
+// AppendArg appends the argument v to the arguments list of msg. Unlike
+// Append, the type of v is checked at compile time. It returns msg so that
+// calls can be chained.
+func AppendArg[T Argument](msg *Message, v T) *Message {
+	msg.Append(v)
+	return msg
 }
 
-// TypeTags returns the type tag string.
-func (msg *Message) TypeTags() (string, error) {
-	if msg == nil {
-		return "", fmt.Errorf("message is nil")
+// UnknownArg is an argument with a type tag that isn't supported by this
+// package, as found in received messages. Since the size of such an argument
+// is unknown, the first one of a message holds the rest of the message as
+// Data and all following arguments are unknown as well, with empty Data.
+// When the message is marshaled again, Tag is written as type tag and Data
+// as is, reproducing the received message.
+type UnknownArg struct {
+	Tag  byte
+	Data []byte
+}
+
+// blobReader is a blob argument whose data is read when the message is
+// marshaled.
+type blobReader struct {
+	r    io.Reader
+	size int32
+}
+
+// AppendBlobReader appends a blob argument of the given size, whose data is
+// read from r when the message is marshaled, instead of being held by the
+// message. Since r is read only once, the message can only be marshaled
+// once. MarshalBinary fails if r yields fewer than size bytes.
+func (msg *Message) AppendBlobReader(r io.Reader, size int) error {
+	if size < 0 || size > math.MaxInt32 {
+		return fmt.Errorf("invalid blob size %d", size)
 	}
+	msg.Append(&blobReader{r: r, size: int32(size)})
+	return nil
+}
 
-	tags := ","
-	for _, m := range msg.Arguments {
-		s, err := getTypeTag(m)
-		if err != nil {
-			return "", err
+// AppendChecked appends the given arguments to the arguments list. If any of
+// the arguments has an unsupported type, an error naming the type is returned
+// and no argument is appended.
+func (msg *Message) AppendChecked(args ...interface{}) error {
+	for _, arg := range args {
+		if _, err := appendTypeTag(nil, arg); err != nil {
+			return err
 		}
-		tags += s
 	}
-
-	return tags, nil
+	msg.Append(args...)
+	return nil
 }
 
-// String implements the fmt.Stringer interface.
-func (msg *Message) String() string {
-	if msg == nil {
-		return ""
+// SetArgument replaces the argument at index with v. An error is returned if
+// index is out of range or v has an unsupported type.
+func (msg *Message) SetArgument(index int, v interface{}) error {
+	if _, err := msg.argument(index); err != nil {
+		return err
+	}
+	if _, err := appendTypeTag(nil, v); err != nil {
+		return err
 	}
+	msg.Arguments[index] = v
+	return nil
+}
 
-	tags, err := msg.TypeTags()
-	if err != nil {
-		return ""
+// InsertArgument inserts v before the argument at index, moving the following
+// arguments back. If index equals the number of arguments, v is appended. An
+// error is returned if index is out of range or v has an unsupported type.
+func (msg *Message) InsertArgument(index int, v interface{}) error {
+	if index < 0 || index > len(msg.Arguments) {
+		return fmt.Errorf("argument index %d out of range [0, %d]", index, len(msg.Arguments))
 	}
+	if _, err := appendTypeTag(nil, v); err != nil {
+		return err
+	}
+	msg.Arguments = append(msg.Arguments, nil)
+	copy(msg.Arguments[index+1:], msg.Arguments[index:])
+	msg.Arguments[index] = v
+	return nil
+}
 
-	formatString := "%s %s"
-	var args []interface{}
-	args = append(args, msg.Address)
-	args = append(args, tags)
+// RemoveArgument removes the argument at index, moving the following
+// arguments forward. An error is returned if index is out of range.
+func (msg *Message) RemoveArgument(index int) error {
+	if _, err := msg.argument(index); err != nil {
+		return err
+	}
+	n := len(msg.Arguments)
+	copy(msg.Arguments[index:], msg.Arguments[index+1:])
+	msg.Arguments[n-1] = nil
+	msg.Arguments = msg.Arguments[:n-1]
+	return nil
+}
 
-	for _, arg := range msg.Arguments {
-		switch arg.(type) {
-		case bool, int32, int64, float32, float64, string:
-			formatString += " %v"
-			args = append(args, arg)
+// Equals returns true if the given OSC Message `m` is equal to the current OSC
+// Message. It checks if the OSC address and the arguments are equal. Returns
+// true if the current object and `m` are equal. Blobs are equal regardless
+// of whether they are stored as []byte or Blob, and arrays of floats
+// regardless of whether they are stored as []float32 or []interface{}, so a
+// message equals itself after a round trip through MarshalBinary and
+// ParsePacket.
+func (msg *Message) Equals(m *Message) bool {
+	return msg.EqualsFunc(m, reflect.DeepEqual)
+}
 
-		case nil:
-			formatString += " %s"
-			args = append(args, "Nil")
+// EqualsFunc reports whether msg and m have the same address and the same
+// number of arguments, and cmp reports all pairs of corresponding arguments
+// as equal. Arrays are compared element by element with cmp as well, so cmp
+// is never called with arrays, and blobs are passed as []byte. Use
+// NumericEqual to treat numbers of different types as equal, e.g. int32(1)
+// and float32(1).
+func (msg *Message) EqualsFunc(m *Message, cmp func(a, b interface{}) bool) bool {
+	if msg == nil || m == nil {
+		return msg == m
+	}
+	return msg.Address == m.Address && argumentsEqual(msg.Arguments, m.Arguments, cmp)
+}
 
-		case []byte:
-			formatString += " %s"
-			args = append(args, "blob")
+// argumentsEqual compares the arguments a and b with cmp, recursing into
+// arrays.
+func argumentsEqual(a, b []interface{}, cmp func(a, b interface{}) bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		argA, argB := normalizeArgument(a[i]), normalizeArgument(b[i])
+		arrayA, okA := argA.([]interface{})
+		arrayB, okB := argB.([]interface{})
+		switch {
+		case okA && okB:
+			if !argumentsEqual(arrayA, arrayB, cmp) {
+				return false
+			}
+		case okA || okB:
+			return false
+		case !cmp(argA, argB):
+			return false
+		}
+	}
+	return true
+}
 
-		case Timetag:
-			formatString += " %d"
-			timeTag := arg.(Timetag)
-			args = append(args, timeTag.TimeTag())
+// normalizeArgument returns arg as the type it is decoded as by ParsePacket,
+// if it has several possible types.
+func normalizeArgument(arg interface{}) interface{} {
+	switch t := arg.(type) {
+	case Blob:
+		return []byte(t)
+	case []float32:
+		array := make([]interface{}, len(t))
+		for i, f := range t {
+			array[i] = f
 		}
+		return array
 	}
+	return arg
+}
 
-	return fmt.Sprintf(formatString, args...)
+// Raw returns the bytes the message was parsed from, if they were kept by
+// ParsePacketRaw or a Server with KeepRawBytes set, and nil otherwise. Use it
+// to forward a message without encoding it again. The bytes aren't updated
+// if the message is modified and must not be modified themselves.
+func (msg *Message) Raw() []byte {
+	return msg.raw
 }
 
-// CountArguments returns the number of arguments.
-func (msg *Message) CountArguments() int {
-	return len(msg.Arguments)
+// Truncated returns true if the message was parsed with
+// ParseOptions.AllowTruncatedArguments and ended before all arguments
+// declared by its type tag string, which are missing from Arguments.
+func (msg *Message) Truncated() bool {
+	return msg.truncated
 }
 
-// MarshalBinary serializes the OSC message to a byte buffer. The byte buffer
-// has the following format:
-// 1. OSC Address Pattern
-// 2. OSC Type Tag String
-// 3. OSC Arguments
-func (msg *Message) MarshalBinary() ([]byte, error) {
-	// We can start with the OSC address and add it to the buffer
-	data := new(bytes.Buffer)
-	if _, err := writePaddedString(msg.Address, data); err != nil {
-		return nil, err
+// Copy returns a deep copy of the message. Blob arguments are copied as well,
+// so the copy can be modified without affecting msg and vice versa. Only the
+// readers of blobs appended with AppendBlobReader are shared.
+func (msg *Message) Copy() *Message {
+	c := &Message{Address: msg.Address, raw: msg.raw}
+	if msg.Arguments != nil {
+		c.Arguments = copyArguments(msg.Arguments)
 	}
+	return c
+}
 
-	// Type tag string starts with ","
-	typetags := []byte{','}
-
-	// Process the type tags and collect all arguments
-	payload := new(bytes.Buffer)
-	for _, arg := range msg.Arguments {
-		// FIXME: Use t instead of arg
+// copyArguments returns a deep copy of args.
+func copyArguments(args []interface{}) []interface{} {
+	c := make([]interface{}, len(args))
+	for i, arg := range args {
 		switch t := arg.(type) {
-		default:
-			return nil, fmt.Errorf("OSC - unsupported type: %T", t)
-
-		case bool:
-			if arg.(bool) == true {
-				typetags = append(typetags, 'T')
-			} else {
-				typetags = append(typetags, 'F')
+		case []byte:
+			if t != nil {
+				arg = append([]byte{}, t...)
 			}
-
-		case nil:
-			typetags = append(typetags, 'N')
-
-		case int32:
-			typetags = append(typetags, 'i')
-			if err := binary.Write(payload, binary.BigEndian, int32(t)); err != nil {
-				return nil, err
+		case Blob:
+			if t != nil {
+				arg = append(Blob{}, t...)
 			}
-
-		case float32:
-			typetags = append(typetags, 'f')
-			if err := binary.Write(payload, binary.BigEndian, float32(t)); err != nil {
-				return nil, err
+		case []interface{}:
+			if t != nil {
+				arg = copyArguments(t)
 			}
-
-		case string:
-			typetags = append(typetags, 's')
-			if _, err := writePaddedString(t, payload); err != nil {
-				return nil, err
+		case []float32:
+			if t != nil {
+				arg = append([]float32{}, t...)
 			}
-
-		case []byte:
-			typetags = append(typetags, 'b')
-			if _, err := writeBlob(t, payload); err != nil {
-				return nil, err
+		case UnknownArg:
+			if t.Data != nil {
+				t.Data = append([]byte{}, t.Data...)
+				arg = t
 			}
+		}
+		c[i] = arg
+	}
+	return c
+}
 
-		case int64:
-			typetags = append(typetags, 'h')
-			if err := binary.Write(payload, binary.BigEndian, int64(t)); err != nil {
-				return nil, err
-			}
+// Clear clears the OSC address and all arguments.
+func (msg *Message) Clear() {
+	msg.Address = ""
+	msg.ClearData()
+}
 
-		case float64:
-			typetags = append(typetags, 'd')
-			if err := binary.Write(payload, binary.BigEndian, float64(t)); err != nil {
-				return nil, err
+// ClearData removes all arguments from the OSC Message.
+func (msg *Message) ClearData() {
+	msg.Arguments = msg.Arguments[len(msg.Arguments):]
+	msg.tags.Store(nil)
+}
+
+// Match returns true, if the OSC address pattern of the OSC Message matches the given
+// address. The match is case sensitive! The pattern is matched part by part,
+// so wildcards like '*' never match across '/': "/a/*" matches "/a/b", but
+// not "/a/b/c", which is matched by "/a/*/*". The pattern "*" matches every
+// address.
+func (msg *Message) Match(addr string) bool {
+	if msg.Address == "*" {
+		return true
+	}
+	return matchAddress(msg.Address, addr)
+}
+
+// MatchPattern reports whether the address of the message matches the given
+// OSC address pattern, using the same rules as the StandardDispatcher. An
+// error is returned if the pattern is invalid.
+//
+// Both methods exist because the roles are reversed: Match treats the
+// message's address as the pattern, like a received message is matched
+// against the addresses of handlers, whereas MatchPattern treats the
+// message's address as a plain address, e.g. to filter messages with a
+// pattern held by the caller. Both use the same matcher, but only
+// MatchPattern validates the pattern; Match keeps its signature for
+// compatibility.
+func (msg *Message) MatchPattern(pattern string) (bool, error) {
+	if err := ValidatePattern(pattern); err != nil {
+		return false, err
+	}
+	return matchAddress(pattern, msg.Address), nil
+}
+
+// TypeTags returns the type tag string. It is cached, see Message.
+func (msg *Message) TypeTags() (string, error) {
+	if msg == nil {
+		return "", fmt.Errorf("message is nil")
+	}
+	if tags := msg.tags.Load(); tags != nil && typeTagsMatch((*tags)[1:], msg.Arguments) {
+		return *tags, nil
+	}
+	b, err := msg.appendTypeTags(make([]byte, 0, len(msg.Arguments)+1))
+	if err != nil {
+		return "", err
+	}
+	tags := string(b)
+	msg.tags.Store(&tags)
+	return tags, nil
+}
+
+// TypeTagsBytes returns the type tag string as a byte slice, which the caller
+// may modify.
+func (msg *Message) TypeTagsBytes() ([]byte, error) {
+	tags, err := msg.TypeTags()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(tags), nil
+}
+
+// typeTagsMatch reports whether tags, without the leading ',', is the type
+// tag string of args. Unlike building the type tag string, it doesn't
+// allocate.
+func typeTagsMatch(tags string, args []interface{}) bool {
+	for _, arg := range args {
+		var ok bool
+		if tags, ok = trimTypeTag(tags, arg); !ok {
+			return false
+		}
+	}
+	return tags == ""
+}
+
+// trimTypeTag reports whether tags starts with the type tag of arg, as
+// appended by appendTypeTag, and returns the rest of tags.
+func trimTypeTag(tags string, arg interface{}) (string, bool) {
+	switch t := arg.(type) {
+	case []float32:
+		n := len(t) + 2
+		if len(tags) < n || tags[0] != '[' || tags[n-1] != ']' ||
+			strings.Count(tags[1:n-1], "f") != len(t) {
+			return "", false
+		}
+		return tags[n:], true
+	case []interface{}:
+		if tags == "" || tags[0] != '[' {
+			return "", false
+		}
+		tags = tags[1:]
+		for _, elem := range t {
+			var ok bool
+			if tags, ok = trimTypeTag(tags, elem); !ok {
+				return "", false
 			}
+		}
+		if tags == "" || tags[0] != ']' {
+			return "", false
+		}
+		return tags[1:], true
+	}
+	tag, err := getTypeTag(arg)
+	if err != nil || tags == "" || tags[0] != tag {
+		return "", false
+	}
+	return tags[1:], true
+}
+
+// appendTypeTags appends the type tag string, starting with ',', to dst and
+// returns the extended slice.
+func (msg *Message) appendTypeTags(dst []byte) ([]byte, error) {
+	dst = append(dst, ',')
+	for _, arg := range msg.Arguments {
+		var err error
+		if dst, err = appendTypeTag(dst, arg); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// appendTypeTag appends the type tag of arg to dst. For arrays, the type tags
+// of the elements are appended, enclosed in '[' and ']'.
+func appendTypeTag(dst []byte, arg interface{}) ([]byte, error) {
+	if floats, ok := arg.([]float32); ok {
+		dst = append(dst, '[')
+		for range floats {
+			dst = append(dst, 'f')
+		}
+		return append(dst, ']'), nil
+	}
+
+	array, ok := arg.([]interface{})
+	if !ok {
+		tag, err := getTypeTag(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, tag), nil
+	}
+
+	dst = append(dst, '[')
+	for _, elem := range array {
+		var err error
+		if dst, err = appendTypeTag(dst, elem); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, ']'), nil
+}
+
+// Range calls fn for each argument with its OSC type tag, as returned by
+// TypeTags, until fn returns false. Arrays are passed as a whole with the tag
+// '['. If an argument has an unsupported type, an error is returned before fn
+// is called.
+func (msg *Message) Range(fn func(tag byte, v interface{}) bool) error {
+	if _, err := msg.TypeTags(); err != nil {
+		return err
+	}
+	for _, arg := range msg.Arguments {
+		tag, _ := getTypeTag(arg)
+		if !fn(tag, arg) {
+			break
+		}
+	}
+	return nil
+}
+
+// String implements the fmt.Stringer interface.
+func (msg *Message) String() string {
+	if msg == nil {
+		return ""
+	}
+
+	tags, err := msg.TypeTags()
+	if err != nil {
+		return ""
+	}
+
+	formatString := "%s %s"
+	var args []interface{}
+	args = append(args, msg.Address)
+	args = append(args, tags)
+
+	for _, arg := range msg.Arguments {
+		switch arg.(type) {
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+			float32, float64, string:
+			formatString += " %v"
+			args = append(args, arg)
+
+		case nil:
+			formatString += " %s"
+			args = append(args, "Nil")
+
+		case []byte, Blob, *blobReader:
+			formatString += " %s"
+			args = append(args, "blob")
+
+		case UnknownArg:
+			formatString += " %s"
+			args = append(args, "unknown")
+
+		case []interface{}, []float32:
+			formatString += " %s"
+			args = append(args, "array")
 
 		case Timetag:
-			typetags = append(typetags, 't')
+			formatString += " %d"
 			timeTag := arg.(Timetag)
-			b, err := timeTag.MarshalBinary()
-			if err != nil {
-				return nil, err
-			}
-			if _, err = payload.Write(b); err != nil {
-				return nil, err
-			}
+			args = append(args, timeTag.TimeTag())
+		}
+	}
+
+	return fmt.Sprintf(formatString, args...)
+}
+
+// CountArguments returns the number of arguments.
+func (msg *Message) CountArguments() int {
+	return len(msg.Arguments)
+}
+
+// CountArgumentsOfType returns the number of arguments with the given OSC
+// type tag, as returned by TypeTags. Like in Range, arrays count as single
+// arguments with the tag '[' and their elements aren't counted. Arguments of
+// unsupported types aren't counted either.
+func (msg *Message) CountArgumentsOfType(tag byte) int {
+	n := 0
+	for _, arg := range msg.Arguments {
+		if t, err := getTypeTag(arg); err == nil && t == tag {
+			n++
 		}
 	}
+	return n
+}
+
+func (msg *Message) isPacket() {}
+
+// MarshalBinary serializes the OSC message to a byte buffer. The byte buffer
+// has the following format:
+// 1. OSC Address Pattern
+// 2. OSC Type Tag String
+// 3. OSC Arguments
+// An error is returned if the address doesn't start with '/'.
+func (msg *Message) MarshalBinary() ([]byte, error) {
+	return msg.AppendBinary(nil)
+}
+
+// AppendBinary appends the binary encoding of the message, as returned by
+// MarshalBinary, to dst and returns the extended slice. dst is only
+// reallocated if its capacity isn't sufficient, so reusing the returned slice
+// for further messages avoids allocating a new buffer every time.
+func (msg *Message) AppendBinary(dst []byte) ([]byte, error) {
+	if err := checkLeadingSlash(msg.Address); err != nil {
+		return nil, err
+	}
+
+	// We can start with the OSC address and add it to the buffer
+	data := bytes.NewBuffer(dst)
+	if _, err := writePaddedString(msg.Address, data); err != nil {
+		return nil, err
+	}
 
 	// Write the type tag string to the data buffer
-	if _, err := writePaddedString(string(typetags), data); err != nil {
+	typetags, err := msg.TypeTags()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writePaddedString(typetags, data); err != nil {
 		return nil, err
 	}
 
 	// Write the payload (OSC arguments) to the data buffer
-	if _, err := data.Write(payload.Bytes()); err != nil {
-		return nil, err
+	for _, arg := range msg.Arguments {
+		if err := writeArgument(arg, data); err != nil {
+			return nil, err
+		}
 	}
 
 	return data.Bytes(), nil
 }
 
+// MarshalInto writes the binary encoding of the message, as returned by
+// MarshalBinary, to the beginning of buf and returns the number of bytes
+// written. If buf is too small, io.ErrShortBuffer is returned and the
+// content of buf is undefined; use AppendBinary to grow the buffer instead.
+func (msg *Message) MarshalInto(buf []byte) (int, error) {
+	data, err := msg.AppendBinary(buf[:0:len(buf)])
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+	return len(data), nil
+}
+
+// writeArgument writes the payload of the argument to the data buffer. The
+// elements of arrays are written one after another.
+func writeArgument(arg interface{}, data *bytes.Buffer) error {
+	switch t := arg.(type) {
+	case int32:
+		if err := binary.Write(data, binary.BigEndian, t); err != nil {
+			return err
+		}
+
+	case int, int8, int16, uint, uint8, uint16, uint32, uint64:
+		v, err := convertInteger(t)
+		if err != nil {
+			return err
+		}
+		return writeArgument(v, data)
+
+	case float32:
+		if err := binary.Write(data, binary.BigEndian, t); err != nil {
+			return err
+		}
+
+	case string:
+		if _, err := writePaddedString(t, data); err != nil {
+			return err
+		}
+
+	case []byte:
+		if _, err := writeBlob(t, data); err != nil {
+			return err
+		}
+
+	case Blob:
+		if _, err := writeBlob(t, data); err != nil {
+			return err
+		}
+
+	case *blobReader:
+		if _, err := writeBlobReader(t, data); err != nil {
+			return err
+		}
+
+	case UnknownArg:
+		if _, err := data.Write(t.Data); err != nil {
+			return err
+		}
+
+	case int64:
+		if err := binary.Write(data, binary.BigEndian, t); err != nil {
+			return err
+		}
+
+	case float64:
+		if err := binary.Write(data, binary.BigEndian, t); err != nil {
+			return err
+		}
+
+	case []interface{}:
+		for _, elem := range t {
+			if err := writeArgument(elem, data); err != nil {
+				return err
+			}
+		}
+
+	case []float32:
+		var b [4]byte
+		data.Grow(4 * len(t))
+		for _, f := range t {
+			binary.BigEndian.PutUint32(b[:], math.Float32bits(f))
+			data.Write(b[:])
+		}
+
+	case Timetag:
+		b, err := t.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if _, err = data.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 ////
 // Bundle
 ////
@@ -386,22 +1451,123 @@ func NewBundle(time time.Time) *Bundle {
 	return &Bundle{Timetag: *NewTimetag(time)}
 }
 
-// Append appends an OSC bundle or OSC message to the bundle.
-func (b *Bundle) Append(pck Packet) error {
-	switch t := pck.(type) {
-	default:
-		return fmt.Errorf("unsupported OSC packet type: only Bundle and Message are supported")
+// NewImmediateBundle returns an OSC Bundle containing the given packets, with
+// the special time tag meaning "immediately". An error is returned if any of
+// the packets is nil.
+func NewImmediateBundle(pkts ...Packet) (*Bundle, error) {
+	b := NewBundle(time.Time{})
+	b.Timetag = *NewTimetagFromTimetag(1)
+	if err := b.Append(pkts...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
 
-	case *Bundle:
-		b.Bundles = append(b.Bundles, t)
+// NewScheduledBundle returns an OSC Bundle containing the given packets,
+// which are to be dispatched at the given time. An error is returned if any
+// of the packets is nil.
+func NewScheduledBundle(at time.Time, pkts ...Packet) (*Bundle, error) {
+	b := NewBundle(at)
+	if err := b.Append(pkts...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
 
-	case *Message:
-		b.Messages = append(b.Messages, t)
+// Append appends OSC bundles and OSC messages to the bundle. An error is
+// returned and nothing is appended if any of the packets is nil.
+func (b *Bundle) Append(pkts ...Packet) error {
+	for _, pck := range pkts {
+		switch t := pck.(type) {
+		case *Bundle:
+			if t == nil {
+				return fmt.Errorf("can't append nil bundle")
+			}
+		case *Message:
+			if t == nil {
+				return fmt.Errorf("can't append nil message")
+			}
+		default:
+			return fmt.Errorf("unsupported OSC packet type: only Bundle and Message are supported")
+		}
 	}
 
+	for _, pck := range pkts {
+		switch t := pck.(type) {
+		case *Bundle:
+			b.Bundles = append(b.Bundles, t)
+		case *Message:
+			b.Messages = append(b.Messages, t)
+		}
+	}
 	return nil
 }
 
+// CountElements returns the number of messages and bundles in the bundle,
+// not including the elements of nested bundles.
+func (b *Bundle) CountElements() int {
+	return len(b.Messages) + len(b.Bundles)
+}
+
+func (b *Bundle) isPacket() {}
+
+// TimedMessage is a message together with the time tag of the bundle it was
+// contained in, as returned by FlattenBundleTimetags.
+type TimedMessage struct {
+	Message *Message
+	Timetag Timetag
+}
+
+// FlattenBundle returns all messages contained in pkt. For a bundle, these
+// are its messages followed by the messages of its nested bundles,
+// recursively, i.e. in the order they are encoded and dispatched. A message
+// is returned by itself. Nil packets yield no messages. Use
+// FlattenBundleTimetags to keep the time tags of the bundles.
+func FlattenBundle(pkt Packet) []*Message {
+	var msgs []*Message
+	walkMessages(pkt, nil, func(msg *Message, _ *Bundle) {
+		msgs = append(msgs, msg)
+	})
+	return msgs
+}
+
+// FlattenBundleTimetags returns all messages contained in pkt like
+// FlattenBundle, each with the time tag of the innermost bundle containing
+// it. A message that isn't contained in a bundle gets the time tag
+// "immediately".
+func FlattenBundleTimetags(pkt Packet) []TimedMessage {
+	var msgs []TimedMessage
+	walkMessages(pkt, nil, func(msg *Message, b *Bundle) {
+		tag := *NewTimetagFromTimetag(1)
+		if b != nil {
+			tag = b.Timetag
+		}
+		msgs = append(msgs, TimedMessage{Message: msg, Timetag: tag})
+	})
+	return msgs
+}
+
+// walkMessages calls fn for all messages in pkt with the innermost bundle
+// containing them, which is parent for a message passed as pkt.
+func walkMessages(pkt Packet, parent *Bundle, fn func(msg *Message, b *Bundle)) {
+	switch t := pkt.(type) {
+	case *Message:
+		if t != nil {
+			fn(t, parent)
+		}
+	case *Bundle:
+		if t == nil {
+			return
+		}
+		for _, msg := range t.Messages {
+			walkMessages(msg, t, fn)
+		}
+		for _, nested := range t.Bundles {
+			walkMessages(nested, t, fn)
+		}
+	}
+}
+
 // MarshalBinary serializes the OSC bundle to a byte array with the following
 // format:
 // 1. Bundle string: '#bundle'
@@ -473,7 +1639,8 @@ func (b *Bundle) MarshalBinary() ([]byte, error) {
 // NewClient creates a new OSC client. The Client is used to send OSC
 // messages and OSC bundles over an UDP network connection. The `ip` argument
 // specifies the IP address and `port` defines the target port where the
-// messages and bundles will be send to.
+// messages and bundles will be send to. IPv6 addresses like "::1" are
+// supported as well.
 func NewClient(ip string, port int) *Client {
 	return &Client{ip: ip, port: port, laddr: nil}
 }
@@ -484,145 +1651,1211 @@ func (c *Client) IP() string { return c.ip }
 // SetIP sets a new IP address.
 func (c *Client) SetIP(ip string) { c.ip = ip }
 
-// Port returns the port.
-func (c *Client) Port() int { return c.port }
+// Port returns the port.
+func (c *Client) Port() int { return c.port }
+
+// SetPort sets a new port.
+func (c *Client) SetPort(port int) { c.port = port }
+
+// SetLocalAddr sets the local address. Like the target address of the
+// client, ip may be a host name, an IPv4 address or an IPv6 address, which
+// may be enclosed in brackets.
+func (c *Client) SetLocalAddr(ip string, port int) error {
+	laddr, err := net.ResolveUDPAddr("udp", hostPort(ip, port))
+	if err != nil {
+		return err
+	}
+	c.laddr = laddr
+	return nil
+}
+
+// SetBroadcast enables or disables sending to broadcast addresses (e.g.
+// 255.255.255.255 or a subnet broadcast address) by setting the SO_BROADCAST
+// socket option on the connections used by Send. Depending on the platform
+// and its firewall configuration, the process may need additional privileges
+// to send broadcast datagrams. An error is returned if the platform doesn't
+// support socket options.
+func (c *Client) SetBroadcast(enable bool) error {
+	if !sockoptSupported {
+		return errSockoptUnsupported
+	}
+	c.broadcast = enable
+	return nil
+}
+
+// Broadcast returns true if sending to broadcast addresses is enabled.
+func (c *Client) Broadcast() bool { return c.broadcast }
+
+// SetInterface sets the network interface that outgoing packets are sent
+// from. The packets are sent with an address of the interface as source
+// address and multicast packets are sent on the interface. An IP address set
+// with SetLocalAddr takes precedence over the interface's addresses, while
+// the port set with SetLocalAddr is still used. Passing nil resets the
+// interface. An error is returned if the interface has no IP address.
+func (c *Client) SetInterface(ifi *net.Interface) error {
+	if ifi == nil {
+		c.ifi = nil
+		return nil
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP != nil {
+			c.ifi = ifi
+			return nil
+		}
+	}
+	return fmt.Errorf("interface %s has no usable address", ifi.Name)
+}
+
+// Interface returns the network interface set with SetInterface or nil.
+func (c *Client) Interface() *net.Interface { return c.ifi }
+
+// SetWriteBuffer sets the size of the operating system's send buffer of the
+// client's socket in bytes, like net.UDPConn.SetWriteBuffer. A larger buffer
+// helps when sending bursts of packets. Typical values are between 64 KiB and
+// a few MiB; the operating system may clamp the size to a system-wide limit,
+// e.g. net.core.wmem_max on Linux. Zero keeps the default size.
+func (c *Client) SetWriteBuffer(bytes int) { c.sndBuf = bytes }
+
+// WriteBuffer returns the send buffer size set with SetWriteBuffer.
+func (c *Client) WriteBuffer() int { return c.sndBuf }
+
+// SetWriteTimeout sets the time after which sending a packet fails with a
+// timeout error if it can't be written, e.g. because the send buffer is
+// full. Zero, the default, disables the timeout.
+func (c *Client) SetWriteTimeout(timeout time.Duration) { c.wtimeout = timeout }
+
+// WriteTimeout returns the timeout set with SetWriteTimeout.
+func (c *Client) WriteTimeout() time.Duration { return c.wtimeout }
+
+// SetFramedPackets makes the client prefix every packet it sends with its
+// size as a big-endian int32, for receivers that read several packets from
+// one datagram, like a Server with FramedPackets set. SendRaw sends its data
+// unchanged regardless. Disabled by default.
+func (c *Client) SetFramedPackets(enable bool) { c.framed = enable }
+
+// FramedPackets returns whether packets are prefixed with their size, as set
+// with SetFramedPackets.
+func (c *Client) FramedPackets() bool { return c.framed }
+
+// SetPersistent makes the client keep the connection opened by the first send
+// and reuse it for all further packets, instead of opening a new connection
+// for every packet. This saves system calls when sending at high rates and
+// keeps the source port of the packets stable. If writing to the connection
+// fails, it is closed and the next send opens a new one. Changes of the
+// target, the local address and the socket options only apply to
+// connections opened afterwards, so call Close after changing them. Request
+// always uses a connection of its own. Disabled by default; disabling it
+// closes the kept connection.
+func (c *Client) SetPersistent(enable bool) {
+	c.mu.Lock()
+	c.persistent = enable
+	c.mu.Unlock()
+	if !enable {
+		c.Close()
+	}
+}
+
+// Persistent returns whether the client keeps its connection open, as set
+// with SetPersistent.
+func (c *Client) Persistent() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.persistent
+}
+
+// Close closes the connection kept open by a persistent client. The client
+// can still be used afterwards and opens a new connection with the next
+// send. Close does nothing if there is no open connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// connect returns the connection to send packets on, which is either the one
+// kept open by a persistent client or a new one.
+func (c *Client) connect() (net.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.persistent {
+		return c.dial()
+	}
+	if c.conn == nil {
+		conn, err := c.dial()
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+// release is called once sending on conn, returned by connect, is done. It
+// closes conn, unless it is kept open and writing to it didn't fail with
+// writeErr.
+func (c *Client) release(conn net.Conn, writeErr error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn == c.conn {
+		if writeErr == nil {
+			return
+		}
+		c.conn = nil
+	}
+	conn.Close()
+}
+
+// write writes data to conn, applying the write timeout of the client.
+func (c *Client) write(conn net.Conn, data []byte) error {
+	if c.wtimeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(c.wtimeout)); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+// dial opens a new connection to the client's target address and sets the
+// size of its send buffer and the DSCP.
+func (c *Client) dial() (net.Conn, error) {
+	conn, err := c.dialConn()
+	if err != nil {
+		return nil, err
+	}
+	if c.sndBuf > 0 {
+		if err := setSocketBuffer(conn, c.sndBuf, false); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.dscp > 0 {
+		if err := setDSCP(conn, c.dscp); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// dialConn opens a new UDP connection to the client's target address,
+// applying the configured local address, interface and socket options.
+// Clients created with NewClientUnix connect to their socket and clients
+// created with Pipe to their in-memory connection instead.
+func (c *Client) dialConn() (net.Conn, error) {
+	if c.unixPath != "" {
+		return net.Dial("unixgram", c.unixPath)
+	}
+	if c.pipe != nil {
+		return &pipeWriter{c.pipe}, nil
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", hostPort(c.ip, c.port))
+	if err != nil {
+		return nil, err
+	}
+
+	d := net.Dialer{
+		Control: func(network, address string, rc syscall.RawConn) error {
+			if !c.broadcast {
+				return nil
+			}
+			return setBroadcast(rc, true)
+		},
+	}
+	laddr, err := c.localAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if laddr != nil {
+		d.LocalAddr = laddr
+	}
+
+	conn, err := d.Dial("udp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	if c.ifi != nil && addr.IP.IsMulticast() {
+		if err := setMulticastInterface(conn.(net.PacketConn), addr.IP, c.ifi); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// hostPort joins host and port to an address like "127.0.0.1:8765" or
+// "[::1]:8765". Brackets around IPv6 addresses are optional.
+func hostPort(host string, port int) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// localAddr returns the local address to send packets to raddr from, based on
+// the local address and the interface of the client. It returns nil if any
+// local address can be used.
+func (c *Client) localAddr(raddr *net.UDPAddr) (*net.UDPAddr, error) {
+	if c.ifi == nil || (c.laddr != nil && c.laddr.IP != nil && !c.laddr.IP.IsUnspecified()) {
+		return c.laddr, nil
+	}
+
+	laddr := &net.UDPAddr{}
+	if c.laddr != nil {
+		laddr.Port = c.laddr.Port
+	}
+
+	addrs, err := c.ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	wantIPv4 := raddr.IP.To4() != nil
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || (ipnet.IP.To4() != nil) != wantIPv4 {
+			continue
+		}
+		laddr.IP = ipnet.IP
+		if ipnet.IP.IsLinkLocalUnicast() {
+			laddr.Zone = c.ifi.Name
+			// Prefer addresses that don't need a zone
+			continue
+		}
+		return laddr, nil
+	}
+	if laddr.IP != nil {
+		return laddr, nil
+	}
+
+	family := "IPv6"
+	if wantIPv4 {
+		family = "IPv4"
+	}
+	return nil, fmt.Errorf("interface %s has no %s address", c.ifi.Name, family)
+}
+
+// SetTypeTagMode sets the type tags used by Send to encode arguments. The
+// default is TypeTagsOSC11. Received packets are always decoded with both
+// sets of type tags.
+func (c *Client) SetTypeTagMode(mode TypeTagMode) { c.tagMode = mode }
+
+// TypeTagMode returns the type tags used by Send to encode arguments.
+func (c *Client) TypeTagMode() TypeTagMode { return c.tagMode }
+
+// SetSendRetries sets the number of times Send and SendContext transmit a
+// packet again after the first transmission, waiting interval in between. This
+// makes it more likely that a packet survives a lossy network, but receivers
+// must be able to handle duplicates. By default, packets are sent once.
+func (c *Client) SetSendRetries(retries int, interval time.Duration) {
+	c.retries = retries
+	c.interval = interval
+}
+
+// SendRetries returns the number of retransmissions and the interval between
+// them, as set with SetSendRetries.
+func (c *Client) SendRetries() (int, time.Duration) { return c.retries, c.interval }
+
+// Send sends an OSC Bundle or an OSC Message.
+func (c *Client) Send(packet Packet) error {
+	return c.SendContext(context.Background(), packet)
+}
+
+// SendContext sends an OSC Bundle or an OSC Message like Send. If the packet
+// is retransmitted, see SetSendRetries, SendContext stops waiting for the
+// next transmission and returns the context's error once ctx is done. Nothing
+// is sent if ctx is already done.
+func (c *Client) SendContext(ctx context.Context, packet Packet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := c.encode(packet)
+	if err != nil {
+		return err
+	}
+	if c.retries <= 0 {
+		return c.SendRaw(data)
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	var writeErr error
+	defer func() { c.release(conn, writeErr) }()
+
+	for i := 0; ; i++ {
+		if writeErr = c.write(conn, data); writeErr != nil {
+			return writeErr
+		}
+		if i == c.retries {
+			return nil
+		}
+
+		timer := time.NewTimer(c.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// encode returns the binary encoding of the packet with the type tags set
+// with SetTypeTagMode, prefixed with its size if set with SetFramedPackets.
+func (c *Client) encode(packet Packet) ([]byte, error) {
+	if c.tagMode == TypeTagsOSC10 {
+		var err error
+		if packet, err = toOSC10(packet); err != nil {
+			return nil, err
+		}
+	}
+	if c.framed {
+		return framePacket(packet)
+	}
+	return packet.MarshalBinary()
+}
+
+// SendRaw sends the given bytes as a single datagram. Use it to send a packet
+// that has been encoded once with MarshalBinary repeatedly, without encoding
+// it again. The data is sent as is and not validated in any way.
+func (c *Client) SendRaw(data []byte) error {
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	err = c.write(conn, data)
+	c.release(conn, err)
+	return err
+}
+
+// toOSC10Arguments returns a copy of args, in which booleans have been
+// replaced by int32 arguments, including the elements of arrays.
+func toOSC10Arguments(args []interface{}) ([]interface{}, error) {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch t := arg.(type) {
+		case bool:
+			converted[i] = int32(boolint(t))
+		case nil:
+			return nil, fmt.Errorf("%w: nil can't be encoded with OSC 1.0 type tags", ErrUnsupportedType)
+		case []interface{}:
+			array, err := toOSC10Arguments(t)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = array
+		default:
+			converted[i] = arg
+		}
+	}
+	return converted, nil
+}
+
+// toOSC10 returns a copy of the packet, in which all arguments that require
+// OSC 1.1 type tags have been replaced by OSC 1.0 arguments.
+func toOSC10(packet Packet) (Packet, error) {
+	switch p := packet.(type) {
+	case *Message:
+		args, err := toOSC10Arguments(p.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Address: p.Address, Arguments: args}, nil
+
+	case *Bundle:
+		bundle := &Bundle{Timetag: p.Timetag}
+		for _, m := range p.Messages {
+			msg, err := toOSC10(m)
+			if err != nil {
+				return nil, err
+			}
+			bundle.Messages = append(bundle.Messages, msg.(*Message))
+		}
+		for _, b := range p.Bundles {
+			nested, err := toOSC10(b)
+			if err != nil {
+				return nil, err
+			}
+			bundle.Bundles = append(bundle.Bundles, nested.(*Bundle))
+		}
+		return bundle, nil
+	}
+	return packet, nil
+}
+
+////
+// Server
+////
+
+// handlerRegistry is implemented by dispatchers that handlers can be
+// registered with, like the StandardDispatcher and the ServeMux.
+type handlerRegistry interface {
+	Handle(addr string, handler Handler) error
+}
+
+// Handle registers the handler for the given OSC address with the server's
+// Dispatcher, which must have a Handle method like the StandardDispatcher
+// and the ServeMux. A StandardDispatcher is created if the server has no
+// Dispatcher yet.
+func (s *Server) Handle(addr string, handler Handler) error {
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+	r, ok := s.Dispatcher.(handlerRegistry)
+	if !ok {
+		return fmt.Errorf("can't register handlers with dispatcher of type %T", s.Dispatcher)
+	}
+	return r.Handle(addr, handler)
+}
+
+// HandleFunc registers the handler function for the given OSC address, like
+// Handle.
+func (s *Server) HandleFunc(addr string, handler func(msg *Message)) error {
+	return s.Handle(addr, HandlerFunc(handler))
+}
+
+// ListenAndServe retrieves incoming OSC packets and dispatches the retrieved
+// OSC packets.
+func (s *Server) ListenAndServe() error {
+	defer s.CloseConnection()
+
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+
+	ln, err := s.listen(s.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.close = ln.Close
+
+	return s.Serve(ln)
+}
+
+// ListenAndServeAll listens on all of the given UDP addresses, e.g. on
+// several ports or interfaces, and dispatches the packets received on any of
+// them to the server's Dispatcher. The server's Addr is ignored. Like
+// ListenAndServe, it returns once serving fails, e.g. when CloseConnection
+// closes all of the connections.
+func (s *Server) ListenAndServeAll(addrs ...string) error {
+	if len(addrs) == 0 {
+		return errors.New("no listen address")
+	}
+	defer s.CloseConnection()
+
+	var conns []net.PacketConn
+	closeAll := func() error {
+		var err error
+		for _, c := range conns {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}
+	for _, addr := range addrs {
+		ln, err := s.listen(addr)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		conns = append(conns, ln)
+	}
+
+	s.close = closeAll
+
+	return s.ServeAll(context.Background(), conns...)
+}
+
+// listen listens on the given UDP address, setting SO_REUSEPORT if ReusePort
+// is set.
+func (s *Server) listen(addr string) (net.PacketConn, error) {
+	var lc net.ListenConfig
+	if s.ReusePort {
+		if reusePortSupported {
+			lc.Control = func(network, address string, rc syscall.RawConn) error {
+				return setReusePort(rc)
+			}
+		} else {
+			s.logf("osc: SO_REUSEPORT isn't supported on this platform, listening on %s without it", addr)
+		}
+	}
+	return lc.ListenPacket(context.Background(), "udp", addr)
+}
+
+// ServeAll serves all of the given connections like ServeContext, fanning
+// the packets received on them into the server's Dispatcher. Once serving
+// any of the connections fails, the others are stopped as well and its error
+// is returned. ServeAll returns the context's error once ctx is done.
+func (s *Server) ServeAll(ctx context.Context, conns ...net.PacketConn) error {
+	// Set the default Dispatcher before serving so that the connections
+	// don't race to install their own
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, len(conns))
+	for _, c := range conns {
+		go func(c net.PacketConn) {
+			errc <- s.ServeContext(ctx, c)
+		}(c)
+	}
+
+	var first error
+	for range conns {
+		err := <-errc
+		if first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
+// Serve retrieves incoming OSC packets from the given connection and dispatches
+// retrieved OSC packets. If something goes wrong an error is returned. Reads
+// that time out, e.g. due to ReadTimeout, are retried, as are reads failing
+// with a temporary error. Packets that can't be parsed are counted as parse
+// errors, see Stats, logged and dropped without stopping Serve.
+func (s *Server) Serve(c net.PacketConn) error {
+	return s.ServeContext(context.Background(), c)
+}
+
+// ServeContext is like Serve, but returns the context's error once ctx is
+// done.
+func (s *Server) ServeContext(ctx context.Context, c net.PacketConn) error {
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+	if s.SocketReadBuffer > 0 {
+		if err := setSocketBuffer(c, s.SocketReadBuffer, true); err != nil {
+			return err
+		}
+	}
+	if err := s.addConn(c); err != nil {
+		return err
+	}
+	defer s.removeConn(c)
+
+	// Abort the current read by moving the read deadline into the past as
+	// soon as the context is done.
+	if ctx.Done() != nil {
+		serveDone := make(chan struct{})
+		watchDone := make(chan struct{})
+		go func() {
+			defer close(watchDone)
+			select {
+			case <-ctx.Done():
+				c.SetReadDeadline(time.Unix(1, 0))
+			case <-serveDone:
+			}
+		}()
+		defer func() {
+			close(serveDone)
+			<-watchDone
+			if ctx.Err() != nil {
+				// Reset the deadline for further reads
+				c.SetReadDeadline(time.Time{})
+			}
+		}()
+	}
+
+	var tempDelay time.Duration
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msg, addr, err := s.readFromConnection(c)
+		if err != nil {
+			if isParseError(err) {
+				s.logf("osc: dropping packet from %v: %v", addr, err)
+				continue
+			}
+			ne, ok := err.(net.Error)
+			if ok && ne.Timeout() {
+				// Either the context is done, which is checked above, or
+				// the ReadTimeout expired
+				tempDelay = 0
+				continue
+			}
+			if ok && ne.Temporary() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if max := 1 * time.Second; tempDelay > max {
+					tempDelay = max
+				}
+				s.logf("osc: read error: %v; retrying in %v", err, tempDelay)
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+		if !s.acceptFrom(addr) || s.rejectBundle(msg, addr) {
+			continue
+		}
+		if s.AnswerQueries && s.answerQuery(c, msg, addr) {
+			continue
+		}
+		go s.dispatch(msg, addr)
+	}
+}
+
+// ServeOne receives a single OSC packet from the given connection and
+// dispatches it to the server's Dispatcher before returning, which allows the
+// caller to drive the receive loop. Unlike Serve, the packet is dispatched
+// synchronously. The exception are bundles with a time tag in the future,
+// which are dispatched from another goroutine once they are due, like by
+// Serve, so their handlers may still be called after ServeOne returns; use
+// ReceivePacket and DispatchPacket to dispatch them right away instead.
+// Packets dropped due to the rate limit are not reported as error. If the context is canceled or its deadline is exceeded while
+// waiting for a packet, the context's error is returned.
+func (s *Server) ServeOne(ctx context.Context, c PacketReader) error {
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+
+	// Abort the read by moving the read deadline into the past as soon as the
+	// context is done.
+	readDone := make(chan struct{})
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		select {
+		case <-ctx.Done():
+			c.SetReadDeadline(time.Unix(1, 0))
+		case <-readDone:
+		}
+	}()
+
+	packet, addr, err := s.readFromConnection(c)
+	close(readDone)
+	<-watchDone
+
+	if ctx.Err() != nil {
+		// Reset the deadline for the next read
+		c.SetReadDeadline(time.Time{})
+		if err != nil {
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if !s.acceptFrom(addr) || s.rejectBundle(packet, addr) {
+		return nil
+	}
+	if w, ok := c.(packetWriter); ok && s.AnswerQueries && s.answerQuery(w, packet, addr) {
+		return nil
+	}
+	s.dispatch(packet, addr)
+	return nil
+}
+
+// rejectBundle returns true if packet is a bundle that must be dropped
+// because DisallowBundles is set.
+func (s *Server) rejectBundle(packet Packet, addr net.Addr) bool {
+	if !s.DisallowBundles || !IsBundle(packet) {
+		return false
+	}
+	s.stats.bundlesRejected.Add(1)
+	s.logf("osc: dropping bundle from %v", addr)
+	return true
+}
+
+// dispatch passes the packet received from addr to the server's Dispatcher.
+// Bundles nested deeper than ParseOptions.MaxBundleDepth are dropped.
+func (s *Server) dispatch(packet Packet, addr net.Addr) {
+	if err := checkBundleDepth(packet, s.ParseOptions.maxBundleDepth()); err != nil {
+		s.logf("osc: dropping packet from %v: %v", addr, err)
+		return
+	}
+	if s.AtomicBundles {
+		if b, ok := packet.(*Bundle); ok {
+			// Wait for the time tag here, so that the dispatcher dispatches
+			// the bundle right away while the lock is held
+			if wait := b.Timetag.ExpiresIn(); wait > 0 {
+				time.AfterFunc(wait, func() { s.dispatch(b, addr) })
+				return
+			}
+			s.dispatchMu.Lock()
+			defer s.dispatchMu.Unlock()
+		} else {
+			s.dispatchMu.RLock()
+			defer s.dispatchMu.RUnlock()
+		}
+	}
+
+	if d, ok := s.Dispatcher.(AddrDispatcher); ok {
+		d.DispatchFrom(packet, addr)
+		return
+	}
+	s.Dispatcher.Dispatch(packet)
+}
+
+// DispatchPacket passes the packet to the server's Dispatcher like Serve does
+// after receiving it, but synchronously and without a network connection,
+// e.g. to test handlers. Bundles are dropped if DisallowBundles is set or
+// they are nested too deeply. Unlike Serve, the messages of bundles are
+// dispatched right away regardless of their time tags, so that all handlers
+// have returned when DispatchPacket returns. Handlers implementing
+// AddrHandler get a nil address.
+//
+// It returns the number of handlers the messages were passed to, counting
+// the default handler. If the Dispatcher is neither a StandardDispatcher nor
+// a ServeMux, the packet is passed to its Dispatch method and -1 is
+// returned, as the number is unknown.
+func (s *Server) DispatchPacket(packet Packet) int {
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+	if s.rejectBundle(packet, nil) {
+		return 0
+	}
+	if err := checkBundleDepth(packet, s.ParseOptions.maxBundleDepth()); err != nil {
+		s.logf("osc: dropping packet: %v", err)
+		return 0
+	}
+
+	if s.AtomicBundles {
+		if IsBundle(packet) {
+			s.dispatchMu.Lock()
+			defer s.dispatchMu.Unlock()
+		} else {
+			s.dispatchMu.RLock()
+			defer s.dispatchMu.RUnlock()
+		}
+	}
+
+	if d, ok := s.Dispatcher.(handlerCounter); ok {
+		return d.dispatchNow(packet, nil)
+	}
+	s.Dispatcher.Dispatch(packet)
+	return -1
+}
+
+// CloseConnection forcibly closes a server's connection.
+//
+// This causes a "use of closed network connection" error the next time the
+// server attempts to read from the connection.
+func (s *Server) CloseConnection() error {
+	if s.close == nil {
+		return nil
+	}
+
+	return s.close()
+}
+
+// LocalAddr returns the local address of the connection the server is
+// serving on, e.g. to find out the port the operating system assigned when
+// listening on ":0". If the server serves several connections, e.g. with
+// ServeAll, the address of the one served first is returned. It returns nil
+// if the server isn't serving. Since ListenAndServe blocks, LocalAddr may
+// still return nil right after it has been started in another goroutine; to
+// know the address before serving, listen with net.ListenPacket and pass the
+// connection to Serve instead.
+func (s *Server) LocalAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conns) == 0 {
+		return nil
+	}
+	return s.conns[0].LocalAddr()
+}
+
+// LocalAddrs returns the local addresses of all connections the server is
+// serving on, in the order they were started to be served.
+func (s *Server) LocalAddrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addrs := make([]net.Addr, len(s.conns))
+	for i, c := range s.conns {
+		addrs[i] = c.LocalAddr()
+	}
+	return addrs
+}
+
+// logf logs a formatted message to the server's Logger, if one is set.
+func (s *Server) logf(format string, v ...interface{}) {
+	if s.Logger == nil {
+		return
+	}
+	s.Logger.Printf(format, v...)
+}
+
+// PacketReader is the part of the net.PacketConn interface needed to receive
+// packets. Any net.PacketConn can be used as PacketReader.
+type PacketReader interface {
+	ReadFrom(p []byte) (n int, addr net.Addr, err error)
+	SetReadDeadline(t time.Time) error
+}
+
+// ReceivePacket listens for incoming OSC packets and returns the packet if one is received.
+func (s *Server) ReceivePacket(c PacketReader) (Packet, error) {
+	p, _, err := s.readFromConnection(c)
+	return p, err
+}
+
+// ReceivePacketFrom listens for incoming OSC packets and returns the packet
+// and the address it was received from if one is received. The address is
+// also returned together with the error if the packet can't be parsed.
+func (s *Server) ReceivePacketFrom(c PacketReader) (Packet, net.Addr, error) {
+	return s.readFromConnection(c)
+}
+
+// readFromConnection retrieves OSC packets.
+func (s *Server) readFromConnection(c PacketReader) (Packet, net.Addr, error) {
+	if p, ok := s.popPending(c); ok {
+		return p.packet, p.addr, nil
+	}
 
-// SetPort sets a new port.
-func (c *Client) SetPort(port int) { c.port = port }
+	if s.ReadTimeout != 0 {
+		if err := c.SetReadDeadline(time.Now().Add(s.ReadTimeout)); err != nil {
+			return nil, nil, err
+		}
+	}
 
-// SetLocalAddr sets the local address.
-func (c *Client) SetLocalAddr(ip string, port int) error {
-	laddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", ip, port))
-	if err != nil {
-		return err
+	size := s.ReadBufferSize
+	if size <= 0 {
+		size = DefaultReadBufferSize
 	}
-	c.laddr = laddr
-	return nil
-}
 
-// Send sends an OSC Bundle or an OSC Message.
-func (c *Client) Send(packet Packet) error {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", c.ip, c.port))
+	// Read into a buffer with an extra byte to detect datagrams that are
+	// truncated because they exceed the buffer size.
+	data := make([]byte, size+1)
+	n, addr, err := c.ReadFrom(data)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	conn, err := net.DialUDP("udp", c.laddr, addr)
-	if err != nil {
-		return err
+	s.stats.packetsReceived.Add(1)
+	if s.OnRawPacket != nil {
+		raw := data[:n]
+		if n > size {
+			raw = data[:size]
+		}
+		s.OnRawPacket(raw, addr)
+	}
+	if n > size {
+		s.stats.parseErrors.Add(1)
+		return nil, addr, ErrPacketTruncated
 	}
-	defer conn.Close()
 
-	data, err := packet.MarshalBinary()
-	if err != nil {
-		return err
+	if s.FramedPackets {
+		packets, err := readFramedPackets(data[:n], s.ParseOptions, s.KeepRawBytes)
+		if err != nil {
+			s.stats.parseErrors.Add(1)
+			return nil, addr, err
+		}
+		for _, p := range packets {
+			s.countTruncated(p, addr)
+		}
+		if len(packets) > 1 {
+			s.mu.Lock()
+			if s.pending == nil {
+				s.pending = make(map[PacketReader][]pendingPacket)
+			}
+			for _, p := range packets[1:] {
+				s.pending[c] = append(s.pending[c], pendingPacket{packet: p, addr: addr})
+			}
+			s.mu.Unlock()
+		}
+		return packets[0], addr, nil
 	}
 
-	if _, err = conn.Write(data); err != nil {
-		return err
+	p, err := s.ParseOptions.parse(data[:n])
+	if err != nil {
+		s.stats.parseErrors.Add(1)
+		return nil, addr, err
 	}
-	return nil
+	if s.KeepRawBytes {
+		attachRaw(p, data[:n])
+	}
+	s.countTruncated(p, addr)
+	return p, addr, nil
 }
 
-////
-// Server
-////
-
-// ListenAndServe retrieves incoming OSC packets and dispatches the retrieved
-// OSC packets.
-func (s *Server) ListenAndServe() error {
-	defer s.CloseConnection()
-
-	if s.Dispatcher == nil {
-		s.Dispatcher = NewStandardDispatcher()
-	}
+// isParseError reports whether err is returned for a received packet that
+// can't be parsed, including datagrams exceeding the read buffer, as opposed
+// to an error of the connection.
+func isParseError(err error) bool {
+	return errors.Is(err, ErrEmptyPacket) || errors.Is(err, ErrShortPacket) ||
+		errors.Is(err, ErrInvalidPacket) || errors.Is(err, ErrPacketTruncated)
+}
 
-	ln, err := net.ListenPacket("udp", s.Addr)
-	if err != nil {
-		return err
+// countTruncated counts and logs the truncated messages of the packet p
+// received from addr, see ParseOptions.AllowTruncatedArguments.
+func (s *Server) countTruncated(p Packet, addr net.Addr) {
+	if !s.ParseOptions.AllowTruncatedArguments {
+		return
 	}
+	walkMessages(p, nil, func(msg *Message, _ *Bundle) {
+		if msg.truncated {
+			s.stats.truncatedMessages.Add(1)
+			s.logf("osc: truncated arguments in message %s from %v", msg.Address, addr)
+		}
+	})
+}
 
-	s.close = ln.Close
+// popPending removes and returns the next packet that has been read from c
+// in a datagram with multiple framed packets, but not been returned yet.
+func (s *Server) popPending(c PacketReader) (pendingPacket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return s.Serve(ln)
+	queue := s.pending[c]
+	if len(queue) == 0 {
+		return pendingPacket{}, false
+	}
+	if len(queue) == 1 {
+		delete(s.pending, c)
+	} else {
+		s.pending[c] = queue[1:]
+	}
+	return queue[0], true
 }
 
-// Serve retrieves incoming OSC packets from the given connection and dispatches
-// retrieved OSC packets. If something goes wrong an error is returned.
-func (s *Server) Serve(c net.PacketConn) error {
-	var tempDelay time.Duration
-	for {
-		msg, err := s.readFromConnection(c)
+// readFramedPackets reads all packets from data, each of them prefixed with
+// its size as int32. At least one packet must be present. If keepRaw is set,
+// the messages keep the bytes they were parsed from.
+func readFramedPackets(data []byte, opts ParseOptions, keepRaw bool) ([]Packet, error) {
+	var packets []Packet
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: invalid packet frame, %d bytes left", ErrShortPacket, len(data))
+		}
+		size := int32(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if size < 1 {
+			return nil, fmt.Errorf("%w: invalid packet size %d", ErrInvalidPacket, size)
+		}
+		if int(size) > len(data) {
+			return nil, fmt.Errorf("%w: packet size %d exceeds the frame", ErrShortPacket, size)
+		}
+
+		p, err := opts.parse(data[:size])
 		if err != nil {
-			if ne, ok := err.(net.Error); ok && ne.Temporary() {
-				if tempDelay == 0 {
-					tempDelay = 5 * time.Millisecond
-				} else {
-					tempDelay *= 2
-				}
-				if max := 1 * time.Second; tempDelay > max {
-					tempDelay = max
-				}
-				time.Sleep(tempDelay)
-				continue
-			}
-			return err
+			return nil, err
 		}
-		tempDelay = 0
-		go s.Dispatcher.Dispatch(msg)
+		if keepRaw {
+			attachRaw(p, data[:size])
+		}
+		packets = append(packets, p)
+		data = data[size:]
 	}
+	if len(packets) == 0 {
+		return nil, fmt.Errorf("%w: no packet in frame", ErrInvalidPacket)
+	}
+	return packets, nil
 }
 
-// CloseConnection forcibly closes a server's connection.
-//
-// This causes a "use of closed network connection" error the next time the
-// server attempts to read from the connection.
-func (s *Server) CloseConnection() error {
-	if s.close == nil {
-		return nil
+// ParsePacket parses the given msg string and returns a Packet. ErrEmptyPacket
+// is returned if msg is empty and ErrPacketTooShort if it's too short to
+// contain an OSC address. Other errors wrap ErrShortPacket if msg ends
+// prematurely or ErrInvalidPacket if it is malformed or exceeds the limits
+// DefaultMaxArguments and DefaultMaxBundleDepth.
+func ParsePacket(msg string) (Packet, error) {
+	return parsePacketBytes([]byte(msg))
+}
+
+// ParseOptions configures the parsing of packets for interoperability with
+// senders that don't conform to the OSC specification. The zero value
+// parses packets strictly, like ParsePacket.
+type ParseOptions struct {
+	// AllowMissingTypeTags accepts messages that end right after their
+	// address, without a type tag string, as messages without arguments.
+	// Such messages are invalid, but sent by some older implementations.
+	AllowMissingTypeTags bool
+
+	// AllowTruncatedArguments accepts messages that end before all
+	// arguments declared by their type tag string. The arguments read
+	// until then are kept, unclosed arrays are closed, and
+	// Message.Truncated returns true. By default, such messages are
+	// rejected with an error wrapping ErrShortPacket. A Server counts
+	// truncated messages in Stats.TruncatedMessages.
+	AllowTruncatedArguments bool
+
+	// MaxBundleDepth is the maximum number of nested bundles, counting the
+	// outermost one. Deeper packets are rejected with ErrBundleTooDeep to
+	// protect against crafted packets; a Server drops them and keeps
+	// serving. If zero, DefaultMaxBundleDepth is used.
+	MaxBundleDepth int
+
+	// MaxArguments is the maximum number of arguments of a message,
+	// including the elements of arrays. Messages declaring more arguments
+	// in their type tag string are rejected with ErrTooManyArguments before
+	// any argument is decoded, which limits the memory a single packet can
+	// allocate. If zero, DefaultMaxArguments is used.
+	MaxArguments int
+
+	// LittleEndianNumbers decodes int32 ('i'), int64 ('h'), float32 ('f')
+	// and float64 ('d') arguments as little-endian instead of big-endian.
+	// This violates the OSC specification and is only a compatibility shim
+	// for broken senders that encode numbers in their native byte order.
+	// Use it only for connections from such senders, as it garbles the
+	// numbers of all others. Sizes, time tags and the other arguments are
+	// still decoded as big-endian, and packets are always encoded as
+	// big-endian.
+	LittleEndianNumbers bool
+}
+
+// byteOrder returns the byte order of numeric arguments.
+func (o ParseOptions) byteOrder() binary.ByteOrder {
+	if o.LittleEndianNumbers {
+		return binary.LittleEndian
 	}
+	return binary.BigEndian
+}
 
-	return s.close()
+// DefaultMaxBundleDepth is the maximum number of nested bundles accepted when
+// parsing packets, unless ParseOptions.MaxBundleDepth is set.
+const DefaultMaxBundleDepth = 32
+
+// DefaultMaxArguments is the maximum number of arguments of a message
+// accepted when parsing packets, unless ParseOptions.MaxArguments is set. It
+// exceeds the number of arguments that fit into a UDP datagram.
+const DefaultMaxArguments = 1 << 16
+
+// maxArguments returns the maximum number of arguments of a message.
+func (o ParseOptions) maxArguments() int {
+	if o.MaxArguments > 0 {
+		return o.MaxArguments
+	}
+	return DefaultMaxArguments
 }
 
-// ReceivePacket listens for incoming OSC packets and returns the packet if one is received.
-func (s *Server) ReceivePacket(c net.PacketConn) (Packet, error) {
-	return s.readFromConnection(c)
+// maxBundleDepth returns the maximum number of nested bundles.
+func (o ParseOptions) maxBundleDepth() int {
+	if o.MaxBundleDepth > 0 {
+		return o.MaxBundleDepth
+	}
+	return DefaultMaxBundleDepth
 }
 
-// readFromConnection retrieves OSC packets.
-func (s *Server) readFromConnection(c net.PacketConn) (Packet, error) {
-	if s.ReadTimeout != 0 {
-		if err := c.SetReadDeadline(time.Now().Add(s.ReadTimeout)); err != nil {
-			return nil, err
+// checkBundleDepth returns an error wrapping ErrBundleTooDeep if p contains
+// more than max nested bundles. It doesn't recurse, so that it can't
+// overflow the stack itself.
+func checkBundleDepth(p Packet, max int) error {
+	type level struct {
+		b     *Bundle
+		depth int
+	}
+	b, ok := AsBundle(p)
+	if !ok {
+		return nil
+	}
+	stack := []level{{b, 1}}
+	for len(stack) > 0 {
+		l := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if l.depth > max {
+			return fmt.Errorf("%w: more than %d levels", ErrBundleTooDeep, max)
+		}
+		for _, nested := range l.b.Bundles {
+			stack = append(stack, level{nested, l.depth + 1})
 		}
 	}
+	return nil
+}
 
-	data := make([]byte, 65535)
-	n, _, err := c.ReadFrom(data)
-	if err != nil {
-		return nil, err
-	}
+// Parse parses the packet contained in data like ParsePacket, applying the
+// options.
+func (o ParseOptions) Parse(data []byte) (Packet, error) {
+	return o.parse(data)
+}
 
-	var start int
-	p, err := readPacket(bufio.NewReader(bytes.NewBuffer(data)), &start, n)
+// ParsePacketRaw parses the packet contained in data like ParsePacket, but
+// every message of the packet keeps the bytes it was parsed from, which are
+// returned by Message.Raw. The messages share data, which must not be
+// modified afterwards.
+func ParsePacketRaw(data []byte) (Packet, error) {
+	p, err := parsePacketBytes(data)
 	if err != nil {
 		return nil, err
 	}
+	attachRaw(p, data)
 	return p, nil
 }
 
-// ParsePacket parses the given msg string and returns a Packet
-func ParsePacket(msg string) (Packet, error) {
+// attachRaw sets the raw bytes of all messages of the packet p, which has
+// been parsed from data.
+func attachRaw(p Packet, data []byte) {
+	switch t := p.(type) {
+	case *Message:
+		t.raw = data
+	case *Bundle:
+		// Skip the bundle tag and the time tag, then match the elements in
+		// order with the messages and bundles of t
+		if len(data) < 16 {
+			return
+		}
+		var nmsg, nbundle int
+		for elements := data[16:]; len(elements) >= 4; {
+			size := int(binary.BigEndian.Uint32(elements))
+			elements = elements[4:]
+			if size < 1 || size > len(elements) {
+				return
+			}
+			if elements[0] == '/' && nmsg < len(t.Messages) {
+				attachRaw(t.Messages[nmsg], elements[:size])
+				nmsg++
+			} else if elements[0] == '#' && nbundle < len(t.Bundles) {
+				attachRaw(t.Bundles[nbundle], elements[:size])
+				nbundle++
+			}
+			elements = elements[size:]
+		}
+	}
+}
+
+// parsePacketBytes parses the packet contained in data strictly.
+func parsePacketBytes(data []byte) (Packet, error) {
+	return ParseOptions{}.parse(data)
+}
+
+// parse parses the packet contained in data.
+func (o ParseOptions) parse(data []byte) (Packet, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyPacket
+	}
+	// The shortest OSC-string is 4 bytes long
+	if len(data) < 4 {
+		return nil, ErrPacketTooShort
+	}
+	// The reader buffers all of data, so that the size of blobs can be
+	// checked against it
 	var start int
-	p, err := readPacket(bufio.NewReader(bytes.NewBufferString(msg)), &start, len(msg))
-	if err != nil {
-		return nil, err
+	p, err := o.readPacket(bufio.NewReaderSize(bytes.NewReader(data), len(data)), &start, len(data), 0)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("%w: %v", ErrShortPacket, err)
 	}
-	return p, nil
+	return p, err
 }
 
-// receivePacket receives an OSC packet from the given reader.
-func readPacket(reader *bufio.Reader, start *int, end int) (Packet, error) {
+// readPacket receives an OSC packet from the given reader. depth is the
+// number of bundles the packet is nested in.
+func (o ParseOptions) readPacket(reader *bufio.Reader, start *int, end int, depth int) (Packet, error) {
 	//var buf []byte
 	buf, err := reader.Peek(1)
 	if err != nil {
@@ -631,26 +2864,29 @@ func readPacket(reader *bufio.Reader, start *int, end int) (Packet, error) {
 
 	// An OSC Message starts with a '/'
 	if buf[0] == '/' {
-		packet, err := readMessage(reader, start)
+		packet, err := o.readMessage(reader, start, end)
 		if err != nil {
 			return nil, err
 		}
 		return packet, nil
 	}
 	if buf[0] == '#' { // An OSC bundle starts with a '#'
-		packet, err := readBundle(reader, start, end)
+		if max := o.maxBundleDepth(); depth >= max {
+			return nil, fmt.Errorf("%w: more than %d levels", ErrBundleTooDeep, max)
+		}
+		packet, err := o.readBundle(reader, start, end, depth+1)
 		if err != nil {
 			return nil, err
 		}
 		return packet, nil
 	}
 
-	var p Packet
-	return p, nil
+	return nil, fmt.Errorf("%w: must start with '/' or '#'", ErrInvalidPacket)
 }
 
-// readBundle reads an Bundle from reader.
-func readBundle(reader *bufio.Reader, start *int, end int) (*Bundle, error) {
+// readBundle reads an Bundle from reader. depth is the nesting level of the
+// bundle, starting at 1.
+func (o ParseOptions) readBundle(reader *bufio.Reader, start *int, end int, depth int) (*Bundle, error) {
 	// Read the '#bundle' OSC string
 	startTag, n, err := readPaddedString(reader)
 	if err != nil {
@@ -659,7 +2895,7 @@ func readBundle(reader *bufio.Reader, start *int, end int) (*Bundle, error) {
 	*start += n
 
 	if startTag != bundleTagString {
-		return nil, fmt.Errorf("Invalid bundle start tag: %s", startTag)
+		return nil, fmt.Errorf("%w: invalid bundle start tag %s", ErrInvalidPacket, startTag)
 	}
 
 	// Read the timetag
@@ -670,7 +2906,7 @@ func readBundle(reader *bufio.Reader, start *int, end int) (*Bundle, error) {
 	*start += 8
 
 	// Create a new bundle
-	bundle := NewBundle(timetagToTime(timeTag))
+	bundle := &Bundle{Timetag: *NewTimetagFromTimetag(timeTag)}
 
 	// Read until the end of the buffer
 	for *start < end {
@@ -680,21 +2916,37 @@ func readBundle(reader *bufio.Reader, start *int, end int) (*Bundle, error) {
 			return nil, err
 		}
 		*start += 4
+		elemEnd := *start + int(length)
+		if length < 1 {
+			return nil, fmt.Errorf("%w: invalid bundle element size %d", ErrInvalidPacket, length)
+		}
+		if elemEnd > end {
+			return nil, fmt.Errorf("%w: bundle element size %d exceeds the bundle", ErrShortPacket, length)
+		}
 
-		p, err := readPacket(reader, start, end)
+		p, err := o.readPacket(reader, start, elemEnd, depth)
 		if err != nil {
 			return nil, err
 		}
 		if err = bundle.Append(p); err != nil {
 			return nil, err
 		}
+
+		// Skip any bytes of the element that haven't been read
+		if *start > elemEnd {
+			return nil, fmt.Errorf("%w: bundle element exceeds its size %d", ErrInvalidPacket, length)
+		}
+		if _, err := reader.Discard(elemEnd - *start); err != nil {
+			return nil, err
+		}
+		*start = elemEnd
 	}
 
 	return bundle, nil
 }
 
-// readMessage from `reader`.
-func readMessage(reader *bufio.Reader, start *int) (*Message, error) {
+// readMessage from `reader`. The message ends at the offset end.
+func (o ParseOptions) readMessage(reader *bufio.Reader, start *int, end int) (*Message, error) {
 	// First, read the OSC address
 	addr, n, err := readPaddedString(reader)
 	if err != nil {
@@ -702,17 +2954,50 @@ func readMessage(reader *bufio.Reader, start *int) (*Message, error) {
 	}
 	*start += n
 
-	// Read all arguments
+	// The type tag string is required, but missing in messages of some
+	// older implementations
 	msg := NewMessage(addr)
-	if err = readArguments(msg, reader, start); err != nil {
+	if *start >= end {
+		if o.AllowMissingTypeTags {
+			return msg, nil
+		}
+		return nil, fmt.Errorf("%w: missing type tag string of message %s", ErrInvalidPacket, addr)
+	}
+
+	// Read all arguments
+	if o.AllowTruncatedArguments {
+		// Read the arguments from the bytes of the message only, so that
+		// a truncated message doesn't extend into the following bundle
+		// element
+		if *start > end {
+			return nil, fmt.Errorf("%w: message exceeds its size", ErrInvalidPacket)
+		}
+		data := make([]byte, end-*start)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		var n int
+		r := bufio.NewReaderSize(bytes.NewReader(data), len(data))
+		if err := o.readArguments(msg, r, &n, len(data)); err != nil {
+			return nil, err
+		}
+		*start = end
+		return msg, nil
+	}
+	if err = o.readArguments(msg, reader, start, end); err != nil {
 		return nil, err
 	}
 
 	return msg, nil
 }
 
-// readArguments from `reader` and add them to the OSC message `msg`.
-func readArguments(msg *Message, reader *bufio.Reader, start *int) error {
+// readArguments from `reader` and add them to the OSC message `msg`, which
+// ends at the offset end. Since the size of arguments with unknown type tags
+// is unknown, the rest of the message is kept as data of the first unknown
+// argument. If AllowTruncatedArguments is set, a message that ends before
+// all of its arguments keeps the arguments read so far and is marked as
+// truncated.
+func (o ParseOptions) readArguments(msg *Message, reader *bufio.Reader, start *int, end int) error {
 	// Read the type tag string
 	var n int
 	typetags, n, err := readPaddedString(reader)
@@ -727,87 +3012,149 @@ func readArguments(msg *Message, reader *bufio.Reader, start *int) error {
 
 	// If the typetag doesn't start with ',', it's not valid
 	if typetags[0] != ',' {
-		return fmt.Errorf("unsupported type tag string %s", typetags)
+		return fmt.Errorf("%w: unsupported type tag string %s", ErrInvalidPacket, typetags)
 	}
 
 	// Remove ',' from the type tag
 	typetags = typetags[1:]
 
-	for _, c := range typetags {
-		switch c {
-		default:
-			return fmt.Errorf("unsupported type tag: %c", c)
+	count := len(typetags) - strings.Count(typetags, "[") - strings.Count(typetags, "]")
+	if max := o.maxArguments(); count > max {
+		return fmt.Errorf("%w: %d arguments, at most %d allowed", ErrTooManyArguments, count, max)
+	}
 
-		case 'i': // int32
-			var i int32
-			if err = binary.Read(reader, binary.BigEndian, &i); err != nil {
-				return err
-			}
-			*start += 4
-			msg.Append(i)
+	// Arrays are collected on a stack, with the arguments of the message at
+	// the bottom
+	stack := [][]interface{}{nil}
+	add := func(arg interface{}) {
+		stack[len(stack)-1] = append(stack[len(stack)-1], arg)
+	}
+	unknown := false
 
-		case 'h': // int64
-			var i int64
-			if err = binary.Read(reader, binary.BigEndian, &i); err != nil {
-				return err
+	for _, c := range []byte(typetags) {
+		switch {
+		case c == '[':
+			stack = append(stack, []interface{}{})
+			continue
+		case c == ']':
+			if len(stack) == 1 {
+				return fmt.Errorf("%w: unexpected ']' in type tag string", ErrInvalidPacket)
 			}
-			*start += 8
-			msg.Append(i)
+			array := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			add(array)
+			continue
+		case unknown:
+			add(UnknownArg{Tag: c})
+			continue
+		}
 
-		case 'f': // float32
-			var f float32
-			if err = binary.Read(reader, binary.BigEndian, &f); err != nil {
-				return err
+		arg, n, err := readArgument(reader, c, o.byteOrder(), true)
+		if err == errUnknownTypeTag {
+			if *start > end {
+				return fmt.Errorf("%w: message exceeds its size", ErrInvalidPacket)
 			}
-			*start += 4
-			msg.Append(f)
-
-		case 'd': // float64/double
-			var d float64
-			if err = binary.Read(reader, binary.BigEndian, &d); err != nil {
+			data := make([]byte, end-*start)
+			if _, err := io.ReadFull(reader, data); err != nil {
 				return err
 			}
-			*start += 8
-			msg.Append(d)
-
-		case 's': // string
-			// TODO: fix reading string value
-			var s string
-			if s, _, err = readPaddedString(reader); err != nil {
-				return err
+			*start = end
+			add(UnknownArg{Tag: c, Data: data})
+			unknown = true
+			continue
+		}
+		if err != nil && o.AllowTruncatedArguments && isShortRead(err) {
+			// Close all open arrays and keep what has been read
+			for len(stack) > 1 {
+				array := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				add(array)
 			}
-			*start += len(s) + padBytesNeeded(len(s))
-			msg.Append(s)
+			msg.truncated = true
+			break
+		}
+		if err != nil {
+			return err
+		}
+		*start += n
+		add(arg)
+	}
+	if len(stack) != 1 {
+		return fmt.Errorf("%w: unclosed array in type tag string", ErrInvalidPacket)
+	}
 
-		case 'b': // blob
-			var buf []byte
-			var n int
-			if buf, n, err = readBlob(reader); err != nil {
-				return err
-			}
-			*start += n
-			msg.Append(buf)
+	msg.Append(stack[0]...)
+	return nil
+}
 
-		case 't': // OSC time tag
-			var tt uint64
-			if err = binary.Read(reader, binary.BigEndian, &tt); err != nil {
-				return nil
-			}
-			*start += 8
-			msg.Append(*NewTimetagFromTimetag(tt))
+// isShortRead reports whether err was caused by data ending prematurely.
+func isShortRead(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrShortPacket)
+}
+
+// errUnknownTypeTag is returned by readArgument for type tags it doesn't
+// support, since the size of their arguments is unknown.
+var errUnknownTypeTag = errors.New("unknown type tag")
+
+// readArgument reads the argument with the given type tag from reader and
+// returns it together with the number of bytes read. Numeric arguments are
+// decoded with the byte order order, which is big-endian unless parsing with
+// ParseOptions.LittleEndianNumbers. If buffered is set, blobs must be
+// buffered by reader as a whole. errUnknownTypeTag is returned for
+// unsupported type tags, without reading anything.
+func readArgument(reader *bufio.Reader, tag byte, order binary.ByteOrder, buffered bool) (interface{}, int, error) {
+	switch tag {
+	case 'i': // int32
+		var i int32
+		if err := binary.Read(reader, order, &i); err != nil {
+			return nil, 0, err
+		}
+		return i, 4, nil
 
-		case 'N': // nil
-			msg.Append(nil)
+	case 'h': // int64
+		var i int64
+		if err := binary.Read(reader, order, &i); err != nil {
+			return nil, 0, err
+		}
+		return i, 8, nil
 
-		case 'T': // true
-			msg.Append(true)
+	case 'f': // float32
+		var f float32
+		if err := binary.Read(reader, order, &f); err != nil {
+			return nil, 0, err
+		}
+		return f, 4, nil
 
-		case 'F': // false
-			msg.Append(false)
+	case 'd': // float64/double
+		var d float64
+		if err := binary.Read(reader, order, &d); err != nil {
+			return nil, 0, err
 		}
-	}
+		return d, 8, nil
 
-	return nil
+	case 's': // string
+		return readPaddedString(reader)
+
+	case 'b': // blob
+		return readBlobFrom(reader, buffered)
+
+	case 't': // OSC time tag
+		var tt uint64
+		if err := binary.Read(reader, binary.BigEndian, &tt); err != nil {
+			return nil, 0, err
+		}
+		return *NewTimetagFromTimetag(tt), 8, nil
+
+	case 'N': // nil
+		return nil, 0, nil
+
+	case 'T': // true
+		return true, 0, nil
+
+	case 'F': // false
+		return false, 0, nil
+	}
+	return nil, 0, errUnknownTypeTag
 }
 
 ////
@@ -822,10 +3169,20 @@ func NewTimetag(timeStamp time.Time) *Timetag {
 		MinValue: uint64(1)}
 }
 
-// NewTimetagFromTimetag creates a new Timetag from the given `timetag`.
+// NewTimetagFromTimetag creates a new Timetag from the given `timetag`. The
+// value is kept as is, so the special value 1 still means "immediately".
 func NewTimetagFromTimetag(timetag uint64) *Timetag {
-	time := timetagToTime(timetag)
-	return NewTimetag(time)
+	return &Timetag{
+		time:     timetagToTime(timetag),
+		timeTag:  timetag,
+		MinValue: uint64(1)}
+}
+
+// IsImmediate returns true if the time tag has the special value 1, which
+// means "immediately" rather than a point in time. Time returns a meaningless
+// point in time for such time tags.
+func (t *Timetag) IsImmediate() bool {
+	return t.timeTag == 1
 }
 
 // Time returns the time.
@@ -836,7 +3193,7 @@ func (t *Timetag) Time() time.Time {
 // FractionalSecond returns the last 32 bits of the OSC time tag. Specifies the
 // fractional part of a second.
 func (t *Timetag) FractionalSecond() uint32 {
-	return uint32(t.timeTag << 32)
+	return uint32(t.timeTag)
 }
 
 // SecondsSinceEpoch returns the first 32 bits (the number of seconds since the
@@ -883,6 +3240,31 @@ func (t *Timetag) ExpiresIn() time.Duration {
 	return seconds
 }
 
+// Before returns true if the time tag t is earlier than other. The special
+// time tag for "immediately" is earlier than all others.
+func (t *Timetag) Before(other Timetag) bool {
+	return t.timeTag < other.timeTag
+}
+
+// After returns true if the time tag t is later than other.
+func (t *Timetag) After(other Timetag) bool {
+	return t.timeTag > other.timeTag
+}
+
+// Add returns the time tag t+d. The duration is rounded to the precision of
+// time tags, about 233 picoseconds, so t.Add(d).Sub(*t) returns d.
+// Adding to the special time tag for "immediately" yields a point in time
+// shortly after the NTP epoch.
+func (t *Timetag) Add(d time.Duration) Timetag {
+	return *NewTimetagFromTimetag(t.timeTag + uint64(durationToFixed(d)))
+}
+
+// Sub returns the duration t-other, rounded to the nearest nanosecond. The
+// time tags must be less than 68 years apart.
+func (t *Timetag) Sub(other Timetag) time.Duration {
+	return fixedToDuration(int64(t.timeTag - other.timeTag))
+}
+
 // timeToTimetag converts the given time to an OSC time tag.
 //
 // An OSC time tag is defined as follows:
@@ -895,12 +3277,52 @@ func (t *Timetag) ExpiresIn() time.Duration {
 // significant bit is a special case meaning "immediately."
 func timeToTimetag(time time.Time) (timetag uint64) {
 	timetag = uint64((secondsFrom1900To1970 + time.Unix()) << 32)
-	return (timetag + uint64(uint32(time.Nanosecond())))
+	return timetag + nanosToFraction(uint64(time.Nanosecond()))
 }
 
 // timetagToTime converts the given timetag to a time object.
 func timetagToTime(timetag uint64) (t time.Time) {
-	return time.Unix(int64((timetag>>32)-secondsFrom1900To1970), int64(timetag&0xffffffff))
+	return time.Unix(int64((timetag>>32)-secondsFrom1900To1970), int64(fractionToNanos(timetag&0xffffffff)))
+}
+
+// nanosToFraction converts nanoseconds below one second to the fractional
+// part of a time tag, in units of 1/2^32 seconds, rounding to the nearest
+// unit. Since a unit is shorter than a nanosecond, fractionToNanos returns
+// the original value.
+func nanosToFraction(ns uint64) uint64 {
+	return (ns<<32 + 5e8) / 1e9
+}
+
+// fractionToNanos converts the fractional part of a time tag to nanoseconds,
+// rounding to the nearest nanosecond.
+func fractionToNanos(fraction uint64) uint64 {
+	return (fraction*1e9 + 1<<31) >> 32
+}
+
+// durationToFixed converts d to the fixed point format of time tags.
+func durationToFixed(d time.Duration) int64 {
+	abs := uint64(d)
+	if d < 0 {
+		abs = uint64(-d)
+	}
+	fixed := int64((abs/1e9)<<32 + nanosToFraction(abs%1e9))
+	if d < 0 {
+		return -fixed
+	}
+	return fixed
+}
+
+// fixedToDuration converts a difference of time tags to a duration.
+func fixedToDuration(fixed int64) time.Duration {
+	abs := uint64(fixed)
+	if fixed < 0 {
+		abs = uint64(-fixed)
+	}
+	d := time.Duration((abs>>32)*1e9 + fractionToNanos(abs&0xffffffff))
+	if fixed < 0 {
+		return -d
+	}
+	return d
 }
 
 ////
@@ -908,8 +3330,16 @@ func timetagToTime(timetag uint64) (t time.Time) {
 ////
 
 // readBlob reads an OSC blob from the blob byte array. Padding bytes are
-// removed from the reader and not returned.
+// removed from the reader and not returned. The whole blob must be buffered
+// by reader.
 func readBlob(reader *bufio.Reader) ([]byte, int, error) {
+	return readBlobFrom(reader, true)
+}
+
+// readBlobFrom reads an OSC blob like readBlob. Unless buffered is set, the
+// blob doesn't need to be buffered by reader, but may be at most
+// maxStreamPacketSize bytes long.
+func readBlobFrom(reader *bufio.Reader, buffered bool) ([]byte, int, error) {
 	// First, get the length
 	var blobLen int32
 	if err := binary.Read(reader, binary.BigEndian, &blobLen); err != nil {
@@ -917,13 +3347,16 @@ func readBlob(reader *bufio.Reader) ([]byte, int, error) {
 	}
 	n := 4 + int(blobLen)
 
-	if blobLen < 1 || blobLen > int32(reader.Buffered()) {
-		return nil, 0, fmt.Errorf("readBlob: invalid blob length %d", blobLen)
+	if blobLen < 0 || !buffered && blobLen > maxStreamPacketSize {
+		return nil, 0, fmt.Errorf("%w: invalid blob length %d", ErrInvalidPacket, blobLen)
+	}
+	if buffered && blobLen > int32(reader.Buffered()) {
+		return nil, 0, fmt.Errorf("%w: blob length %d exceeds the packet", ErrShortPacket, blobLen)
 	}
 
 	// Read the data
 	blob := make([]byte, blobLen)
-	if _, err := reader.Read(blob); err != nil {
+	if _, err := io.ReadFull(reader, blob); err != nil {
 		return nil, 0, err
 	}
 
@@ -931,8 +3364,7 @@ func readBlob(reader *bufio.Reader) ([]byte, int, error) {
 	numPadBytes := padBytesNeeded(int(blobLen))
 	if numPadBytes > 0 {
 		n += numPadBytes
-		dummy := make([]byte, numPadBytes)
-		if _, err := reader.Read(dummy); err != nil {
+		if _, err := reader.Discard(numPadBytes); err != nil {
 			return nil, 0, err
 		}
 	}
@@ -968,6 +3400,32 @@ func writeBlob(data []byte, buf *bytes.Buffer) (int, error) {
 	return 4 + len(data) + numPadBytes, nil
 }
 
+// writeBlobReader writes the blob read from b to the buffer like writeBlob.
+// An error is returned if b's reader doesn't yield the declared size.
+func writeBlobReader(b *blobReader, buf *bytes.Buffer) (int, error) {
+	if err := binary.Write(buf, binary.BigEndian, b.size); err != nil {
+		return 0, err
+	}
+
+	// Read the data directly into the buffer
+	buf.Grow(int(b.size) + 3)
+	n, err := io.CopyN(buf, b.r, int64(b.size))
+	if err != nil {
+		if err == io.EOF {
+			return 0, fmt.Errorf("blob reader yielded %d of %d bytes", n, b.size)
+		}
+		return 0, err
+	}
+
+	numPadBytes := padBytesNeeded(int(b.size))
+	for i := 0; i < numPadBytes; i++ {
+		if err := buf.WriteByte(0); err != nil {
+			return 0, err
+		}
+	}
+	return 4 + int(b.size) + numPadBytes, nil
+}
+
 // readPaddedString reads a padded string from the given reader. The padding
 // bytes are removed from the reader.
 func readPaddedString(reader *bufio.Reader) (string, int, error) {
@@ -982,8 +3440,7 @@ func readPaddedString(reader *bufio.Reader) (string, int, error) {
 	padLen := padBytesNeeded(len(str))
 	if padLen > 0 {
 		n += padLen
-		padBytes := make([]byte, padLen)
-		if _, err = reader.Read(padBytes); err != nil {
+		if _, err = reader.Discard(padLen); err != nil {
 			return "", 0, err
 		}
 	}
@@ -1025,6 +3482,28 @@ func writePaddedString(str string, buf *bytes.Buffer) (int, error) {
 	return n + numPadBytes, nil
 }
 
+// setSocketBuffer sets the size of the receive buffer, if read is true, or
+// the send buffer of c. c must be a *net.UDPConn or another connection that
+// supports setting the buffer size.
+func setSocketBuffer(c interface{}, bytes int, read bool) error {
+	if read {
+		if rc, ok := c.(interface{ SetReadBuffer(int) error }); ok {
+			return rc.SetReadBuffer(bytes)
+		}
+	} else if wc, ok := c.(interface{ SetWriteBuffer(int) error }); ok {
+		return wc.SetWriteBuffer(bytes)
+	}
+	return fmt.Errorf("setting the socket buffer size isn't supported for %T", c)
+}
+
+// boolint returns 1 if b is true and 0 otherwise.
+func boolint(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // padBytesNeeded determines how many bytes are needed to fill up to the next 4
 // byte length.
 func padBytesNeeded(elementLen int) int {
@@ -1040,62 +3519,79 @@ func PrintMessage(msg *Message) {
 	fmt.Println(msg)
 }
 
-// addressExists returns true if the OSC address `addr` is found in `handlers`.
-func addressExists(addr string, handlers map[string]Handler) bool {
-	for h := range handlers {
-		if h == addr {
-			return true
+// convertInteger converts an argument of a Go integer type other than int32
+// and int64 to the one of them it is encoded as, see Message. An error is
+// returned if the value doesn't fit.
+func convertInteger(arg interface{}) (interface{}, error) {
+	switch t := arg.(type) {
+	case int8:
+		return int32(t), nil
+	case int16:
+		return int32(t), nil
+	case uint8:
+		return int32(t), nil
+	case uint16:
+		return int32(t), nil
+	case int:
+		if t < math.MinInt32 || t > math.MaxInt32 {
+			return nil, fmt.Errorf("int value %d overflows int32", t)
 		}
+		return int32(t), nil
+	case uint:
+		if t > math.MaxInt32 {
+			return nil, fmt.Errorf("uint value %d overflows int32", t)
+		}
+		return int32(t), nil
+	case uint32:
+		if t > math.MaxInt32 {
+			return nil, fmt.Errorf("uint32 value %d overflows int32", t)
+		}
+		return int32(t), nil
+	case uint64:
+		if t > math.MaxInt64 {
+			return nil, fmt.Errorf("uint64 value %d overflows int64", t)
+		}
+		return int64(t), nil
 	}
-	return false
-}
-
-// getRegEx compiles and returns a regular expression object for the given
-// address `pattern`.
-func getRegEx(pattern string) *regexp.Regexp {
-	for _, trs := range []struct {
-		old, new string
-	}{
-		{".", `\.`}, // Escape all '.' in the pattern
-		{"(", `\(`}, // Escape all '(' in the pattern
-		{")", `\)`}, // Escape all ')' in the pattern
-		{"*", ".*"}, // Replace a '*' with '.*' that matches zero or more chars
-		{"{", "("},  // Change a '{' to '('
-		{",", "|"},  // Change a ',' to '|'
-		{"}", ")"},  // Change a '}' to ')'
-		{"?", "."},  // Change a '?' to '.'
-	} {
-		pattern = strings.Replace(pattern, trs.old, trs.new, -1)
-	}
-
-	return regexp.MustCompile(pattern)
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, arg)
 }
 
-// getTypeTag returns the OSC type tag for the given argument.
-func getTypeTag(arg interface{}) (string, error) {
+// getTypeTag returns the OSC type tag for the given argument. This is the
+// reference for which Go types are supported as OSC arguments.
+func getTypeTag(arg interface{}) (byte, error) {
 	switch t := arg.(type) {
 	case bool:
-		if arg.(bool) {
-			return "T", nil
+		if t {
+			return 'T', nil
 		}
-		return "F", nil
+		return 'F', nil
 	case nil:
-		return "N", nil
+		return 'N', nil
 	case int32:
-		return "i", nil
+		return 'i', nil
+	case int, int8, int16, uint, uint8, uint16, uint32, uint64:
+		v, err := convertInteger(t)
+		if err != nil {
+			return 0, err
+		}
+		return getTypeTag(v)
 	case float32:
-		return "f", nil
+		return 'f', nil
 	case string:
-		return "s", nil
-	case []byte:
-		return "b", nil
+		return 's', nil
+	case []byte, Blob, *blobReader:
+		return 'b', nil
 	case int64:
-		return "h", nil
+		return 'h', nil
 	case float64:
-		return "d", nil
+		return 'd', nil
 	case Timetag:
-		return "t", nil
+		return 't', nil
+	case UnknownArg:
+		return t.Tag, nil
+	case []interface{}, []float32:
+		return '[', nil
 	default:
-		return "", fmt.Errorf("Unsupported type: %T", t)
+		return 0, fmt.Errorf("%w: %T", ErrUnsupportedType, t)
 	}
 }
@@ -3,9 +3,15 @@ package osc
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +35,329 @@ func TestMessage_Append(t *testing.T) {
 	}
 }
 
+func TestMessage_AppendChecked(t *testing.T) {
+	message := NewMessage("/address")
+	if err := message.AppendChecked(int32(1), "two", float32(3)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := message.CountArguments(), 3; got != want {
+		t.Errorf("Number of arguments should be %d and is %d", want, got)
+	}
+
+	err := message.AppendChecked(int32(4), struct{}{})
+	if err == nil {
+		t.Fatal("Expected error appending a struct")
+	}
+	if !strings.Contains(err.Error(), "struct {}") {
+		t.Errorf("Error should name the unsupported type: %s", err)
+	}
+	if got, want := message.CountArguments(), 3; got != want {
+		t.Errorf("Number of arguments should be %d and is %d", want, got)
+	}
+}
+
+func TestMessage_EditArguments(t *testing.T) {
+	msg := NewMessage("/a", int32(1), "two", float32(3))
+	if _, err := msg.TypeTags(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		desc string
+		edit func() error
+		ok   bool
+		tags string
+	}{
+		{"set", func() error { return msg.SetArgument(1, int64(2)) }, true, ",ihf"},
+		{"set_out_of_range", func() error { return msg.SetArgument(3, int32(4)) }, false, ",ihf"},
+		{"set_unsupported", func() error { return msg.SetArgument(0, struct{}{}) }, false, ",ihf"},
+		{"insert_front", func() error { return msg.InsertArgument(0, true) }, true, ",Tihf"},
+		{"insert_middle", func() error { return msg.InsertArgument(2, "s") }, true, ",Tishf"},
+		{"insert_end", func() error { return msg.InsertArgument(5, nil) }, true, ",TishfN"},
+		{"insert_out_of_range", func() error { return msg.InsertArgument(7, nil) }, false, ",TishfN"},
+		{"insert_unsupported", func() error { return msg.InsertArgument(0, complex64(1)) }, false, ",TishfN"},
+		{"remove_front", func() error { return msg.RemoveArgument(0) }, true, ",ishfN"},
+		{"remove_middle", func() error { return msg.RemoveArgument(2) }, true, ",isfN"},
+		{"remove_end", func() error { return msg.RemoveArgument(3) }, true, ",isf"},
+		{"remove_out_of_range", func() error { return msg.RemoveArgument(-1) }, false, ",isf"},
+	} {
+		if err := tt.edit(); (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok = %t", tt.desc, err, tt.ok)
+		}
+		if got, err := msg.TypeTags(); err != nil || got != tt.tags {
+			t.Errorf("%s: TypeTags() = %q, %v, want = %q", tt.desc, got, err, tt.tags)
+		}
+	}
+}
+
+func TestNewMessageWithArgs(t *testing.T) {
+	msg, err := NewMessageWithArgs("/x", int32(1), "two", float32(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Message{Address: "/x", Arguments: []interface{}{int32(1), "two", float32(3)}}
+	if !msg.Equals(want) {
+		t.Errorf("NewMessageWithArgs() = %v, want = %v", msg, want)
+	}
+
+	if _, err := NewMessageWithArgs("/x", int32(1), map[string]int{}, struct{}{}); err == nil {
+		t.Error("Expected error for unsupported argument")
+	} else if !strings.Contains(err.Error(), "map[string]int") {
+		t.Errorf("Error should name the first unsupported type: %s", err)
+	}
+
+	for _, addr := range []string{"x", "", "/a b"} {
+		if _, err := NewMessageWithArgs(addr); err == nil {
+			t.Errorf("NewMessageWithArgs(%q) expected an error", addr)
+		}
+	}
+}
+
+func TestMessage_MarshalBinaryAddress(t *testing.T) {
+	for _, addr := range []string{"address", "", "a/b"} {
+		if _, err := NewMessage(addr).MarshalBinary(); err == nil {
+			t.Errorf("MarshalBinary() for address %q expected an error", addr)
+		}
+	}
+	if _, err := NewMessage("/").MarshalBinary(); err != nil {
+		t.Errorf("MarshalBinary() for address \"/\" returned error: %s", err)
+	}
+}
+
+func TestMessage_MarshalInto(t *testing.T) {
+	msg := NewMessage("/marshal/into", int32(1), "two", []byte{3}, float64(4))
+	want := mustMarshal(t, msg)
+
+	buf := make([]byte, 128)
+	n, err := msg.MarshalInto(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:n], want) {
+		t.Errorf("MarshalInto() = %q, want = %q", buf[:n], want)
+	}
+	if _, err := msg.MarshalInto(buf[:len(want)-1]); err != io.ErrShortBuffer {
+		t.Errorf("MarshalInto() error = %v, want = %v", err, io.ErrShortBuffer)
+	}
+	if _, err := msg.MarshalInto(nil); err != io.ErrShortBuffer {
+		t.Errorf("MarshalInto(nil) error = %v, want = %v", err, io.ErrShortBuffer)
+	}
+
+	// AppendBinary appends to the existing content and reuses its capacity
+	prefix := []byte("abc")
+	buf = append(make([]byte, 0, 128), prefix...)
+	got, err := msg.AppendBinary(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, append(prefix, want...)) {
+		t.Errorf("AppendBinary() = %q, want = %q", got, append(prefix, want...))
+	}
+	if &got[0] != &buf[0] {
+		t.Error("AppendBinary() reallocated a sufficient buffer")
+	}
+
+	if _, err := NewMessage("invalid").MarshalInto(buf); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+func TestMessage_Copy(t *testing.T) {
+	msg := NewMessage("/address", int32(1), "two", []byte{3, 4}, true, nil, *NewTimetag(time.Now()))
+	c := msg.Copy()
+	if !c.Equals(msg) {
+		t.Fatalf("Copy() = %v, want = %v", c, msg)
+	}
+
+	c.Address = "/changed"
+	c.Arguments[0] = int32(2)
+	c.Arguments[2].([]byte)[0] = 5
+	c.Append("more")
+	want := NewMessage("/address", int32(1), "two", []byte{3, 4}, true, nil, msg.Arguments[5])
+	if !msg.Equals(want) {
+		t.Errorf("original changed to %v, want = %v", msg, want)
+	}
+
+	if c := NewMessage("/empty").Copy(); !c.Equals(NewMessage("/empty")) {
+		t.Errorf("Copy() = %v for message without arguments", c)
+	}
+}
+
+func TestMessage_AppendBlobReader(t *testing.T) {
+	blob := []byte("streamed blob")
+	msg := NewMessage("/address", int32(1))
+	if err := msg.AppendBlobReader(bytes.NewReader(blob), len(blob)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustMarshal(t, NewMessage("/address", int32(1), blob))
+	if !bytes.Equal(got, want) {
+		t.Errorf("MarshalBinary() = %v, want = %v", got, want)
+	}
+
+	// A reader yielding less data than declared is an error
+	msg = NewMessage("/address")
+	if err := msg.AppendBlobReader(bytes.NewReader(blob), len(blob)+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := msg.MarshalBinary(); err == nil {
+		t.Error("expected error for short blob reader")
+	}
+
+	if err := msg.AppendBlobReader(bytes.NewReader(blob), -1); err == nil {
+		t.Error("expected error for negative size")
+	}
+}
+
+func TestAppendArg(t *testing.T) {
+	msg := NewMessage("/address")
+	AppendArg(AppendArg(msg, int32(1)), "two")
+	AppendArg(msg, []byte{3})
+	AppendArg(msg, true)
+
+	tags, err := msg.TypeTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tags, ",isbT"; got != want {
+		t.Errorf("TypeTags() = '%s', want = '%s'", got, want)
+	}
+}
+
+func TestMessage_AppendTime(t *testing.T) {
+	now := time.Now()
+	msg := NewMessage("/address")
+	msg.AppendTime(now)
+
+	data := mustMarshal(t, msg)
+	want := mustMarshal(t, NewMessage("/address", *NewTimetag(now)))
+	if !bytes.Equal(data, want) {
+		t.Errorf("MarshalBinary() = %q, want = %q", data, want)
+	}
+
+	packet, err := ParsePacket(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt, ok := packet.(*Message).Arguments[0].(Timetag)
+	if !ok {
+		t.Fatalf("argument = %T, want = Timetag", packet.(*Message).Arguments[0])
+	}
+	if !tt.Time().Equal(now) {
+		t.Errorf("Time() = %v, want = %v", tt.Time(), now)
+	}
+}
+
+func TestMessage_Blob(t *testing.T) {
+	msg := NewMessage("/address", Blob{1, 2, 3})
+	AppendArg(msg, Blob("xyz"))
+
+	data := mustMarshal(t, msg)
+	want := mustMarshal(t, NewMessage("/address", []byte{1, 2, 3}, []byte("xyz")))
+	if !bytes.Equal(data, want) {
+		t.Errorf("MarshalBinary() = %q, want = %q", data, want)
+	}
+
+	packet, err := ParsePacket(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := packet.(*Message).Arguments, []interface{}{[]byte{1, 2, 3}, []byte("xyz")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Arguments = %#v, want = %#v", got, want)
+	}
+	if !msg.Equals(packet.(*Message)) {
+		t.Errorf("Equals() = false after a round trip of %v", msg)
+	}
+
+	c := msg.Copy()
+	c.Arguments[0].(Blob)[0] = 9
+	if msg.Arguments[0].(Blob)[0] != 1 {
+		t.Error("Copy() shares the data of Blob arguments")
+	}
+	if got, want := msg.String(), "/address ,bb blob blob"; got != want {
+		t.Errorf("String() = %q, want = %q", got, want)
+	}
+}
+
+func TestMessage_AppendFloat32Slice(t *testing.T) {
+	floats := []float32{0.5, -1, 3.25}
+	msg := NewMessage("/wave", int32(1))
+	msg.AppendFloat32Slice(floats)
+	msg.Append("end")
+
+	tags, err := msg.TypeTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ",i[fff]s"; tags != want {
+		t.Errorf("TypeTags() = %q, want = %q", tags, want)
+	}
+
+	// The encoding equals that of the boxed array
+	data := mustMarshal(t, msg)
+	boxed := NewMessage("/wave", int32(1), []interface{}{float32(0.5), float32(-1), float32(3.25)}, "end")
+	if want := mustMarshal(t, boxed); !bytes.Equal(data, want) {
+		t.Errorf("MarshalBinary() = %q, want = %q", data, want)
+	}
+
+	packet, err := ParsePacket(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := packet.(*Message).AsFloat32Slice(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, floats) {
+		t.Errorf("AsFloat32Slice() = %v, want = %v", got, floats)
+	}
+	if !msg.Equals(packet.(*Message)) || !msg.Equals(boxed) {
+		t.Errorf("Equals() = false for %v after a round trip", msg)
+	}
+	if msg.Equals(NewMessage("/wave", int32(1), []float32{0.5, -1}, "end")) {
+		t.Error("Equals() = true for arrays of different lengths")
+	}
+
+	c := msg.Copy()
+	c.Arguments[1].([]float32)[0] = 9
+	if floats[0] != 0.5 {
+		t.Error("Copy() shares the elements of []float32 arguments")
+	}
+}
+
+func TestMessage_Int(t *testing.T) {
+	// A Go int is encoded exactly like an int32.
+	got, err := NewMessage("/address", 123456789).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewMessage("/address", int32(123456789)).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("MarshalBinary() = %q, want = %q", got, want)
+	}
+
+	// Values that don't fit into an int32 are rejected.
+	if strconv.IntSize == 64 {
+		v := int64(math.MaxInt32) + 1
+		big := int(v)
+		msg := NewMessage("/address", big)
+		if _, err := msg.TypeTags(); err == nil {
+			t.Error("TypeTags() expected an error")
+		}
+		if _, err := msg.MarshalBinary(); err == nil {
+			t.Error("MarshalBinary() expected an error")
+		}
+		if err := NewMessage("/address").AppendChecked(big); err == nil {
+			t.Error("AppendChecked() expected an error")
+		}
+	}
+}
+
 func TestMessage_Equals(t *testing.T) {
 	msg1 := NewMessage("/address")
 	msg2 := NewMessage("/address")
@@ -42,6 +371,36 @@ func TestMessage_Equals(t *testing.T) {
 	}
 }
 
+func TestMessage_EqualsFunc(t *testing.T) {
+	msg := NewMessage("/a", int32(1), float32(2.5), "s", []interface{}{int64(3), true})
+	for _, tt := range []struct {
+		desc    string
+		other   *Message
+		numeric bool // Result with NumericEqual
+		strict  bool // Result with reflect.DeepEqual
+	}{
+		{"same", msg.Copy(), true, true},
+		{"numeric_types", NewMessage("/a", float32(1), float64(2.5), "s", []interface{}{int32(3), true}), true, false},
+		{"different_value", NewMessage("/a", int32(2), float32(2.5), "s", []interface{}{int64(3), true}), false, false},
+		{"different_array", NewMessage("/a", int32(1), float32(2.5), "s", []interface{}{int64(3), false}), false, false},
+		{"array_and_scalar", NewMessage("/a", int32(1), float32(2.5), "s", int64(3)), false, false},
+		{"string_and_number", NewMessage("/a", "1", float32(2.5), "s", []interface{}{int64(3), true}), false, false},
+		{"different_address", NewMessage("/b", int32(1), float32(2.5), "s", []interface{}{int64(3), true}), false, false},
+		{"fewer_arguments", NewMessage("/a", int32(1)), false, false},
+		{"nil", nil, false, false},
+	} {
+		if got := msg.EqualsFunc(tt.other, NumericEqual); got != tt.numeric {
+			t.Errorf("%s: EqualsFunc(NumericEqual) = %t, want = %t", tt.desc, got, tt.numeric)
+		}
+		if got := msg.EqualsFunc(tt.other, reflect.DeepEqual); got != tt.strict {
+			t.Errorf("%s: EqualsFunc(reflect.DeepEqual) = %t, want = %t", tt.desc, got, tt.strict)
+		}
+		if got := msg.Equals(tt.other); got != tt.strict {
+			t.Errorf("%s: Equals() = %t, want = %t", tt.desc, got, tt.strict)
+		}
+	}
+}
+
 func TestMessage_TypeTags(t *testing.T) {
 	for _, tt := range []struct {
 		desc string
@@ -61,7 +420,8 @@ func TestMessage_TypeTags(t *testing.T) {
 		{"[]byte", NewMessage("/", []byte{'6'}), ",b", true},
 		{"two_args", NewMessage("/", "123", int32(456)), ",si", true},
 		{"invalid_msg", nil, "", false},
-		{"invalid_arg", NewMessage("/foo/bar", 789), "", false},
+		{"int", NewMessage("/", 789), ",i", true},
+		{"invalid_arg", NewMessage("/foo/bar", struct{}{}), "", false},
 	} {
 		tags, err := tt.msg.TypeTags()
 		if err != nil && tt.ok {
@@ -81,6 +441,204 @@ func TestMessage_TypeTags(t *testing.T) {
 	}
 }
 
+func TestMessage_TypeTagsAfterModification(t *testing.T) {
+	msg := NewMessage("/a", int32(1))
+	for _, tt := range []struct {
+		desc   string
+		modify func()
+		tags   string
+	}{
+		{"initial", func() {}, ",i"},
+		{"append", func() { msg.Append("s") }, ",is"},
+		{"append_arg", func() { AppendArg(msg, true) }, ",isT"},
+		{"clear_data", func() { msg.ClearData() }, ","},
+		{"assign", func() { msg.Arguments = []interface{}{float32(1)} }, ",f"},
+		{"reslice", func() { msg.Arguments = append(msg.Arguments, int64(2))[1:] }, ",h"},
+		{"in_place", func() { msg.Arguments[0] = "s" }, ",s"},
+		{"set_argument", func() { msg.SetArgument(0, true) }, ",T"},
+		{"array", func() { msg.Arguments[0] = []interface{}{int32(1), "s"} }, ",[is]"},
+		{"array_in_place", func() { msg.Arguments[0].([]interface{})[1] = float32(1) }, ",[if]"},
+		{"floats", func() { msg.Arguments[0] = []float32{1, 2} }, ",[ff]"},
+		{"floats_resliced", func() { msg.Arguments[0] = msg.Arguments[0].([]float32)[:1] }, ",[f]"},
+	} {
+		tt.modify()
+		if got, err := msg.TypeTags(); err != nil || got != tt.tags {
+			t.Errorf("%s: TypeTags() = %q, %v, want = %q", tt.desc, got, err, tt.tags)
+		}
+		data, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		p, err := ParsePacket(string(data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if got, _ := p.(*Message).TypeTags(); got != tt.tags {
+			t.Errorf("%s: marshaled type tags = %q, want = %q", tt.desc, got, tt.tags)
+		}
+	}
+}
+
+func TestMessage_TypeTagsCache(t *testing.T) {
+	msg := NewMessage("/a", int32(1), "s", []interface{}{true, nil})
+	want, err := msg.TypeTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		if got, _ := msg.TypeTags(); got != want {
+			t.Errorf("TypeTags() = %q, want = %q", got, want)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("TypeTags() allocated %v times with a cached type tag string", allocs)
+	}
+
+	// The cached string must not be shared with the returned bytes
+	tags, err := msg.TypeTagsBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags[1] = 'x'
+	if got, _ := msg.TypeTags(); got != want {
+		t.Errorf("TypeTags() = %q after modifying TypeTagsBytes(), want = %q", got, want)
+	}
+
+	// Messages may be marshaled concurrently, storing the cache at once
+	msg = NewMessage("/b", int32(2))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := msg.MarshalBinary(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMessage_AppendIntegerTypes(t *testing.T) {
+	type test struct {
+		desc string
+		arg  interface{}
+		want interface{} // Decoded argument, nil if encoding fails
+	}
+	tests := []test{
+		{"int8", int8(math.MinInt8), int32(math.MinInt8)},
+		{"int16", int16(math.MaxInt16), int32(math.MaxInt16)},
+		{"int32", int32(math.MinInt32), int32(math.MinInt32)},
+		{"int64", int64(math.MaxInt64), int64(math.MaxInt64)},
+		{"int", int(math.MaxInt32), int32(math.MaxInt32)},
+		{"uint8", uint8(math.MaxUint8), int32(math.MaxUint8)},
+		{"uint16", uint16(math.MaxUint16), int32(math.MaxUint16)},
+		{"uint32", uint32(math.MaxInt32), int32(math.MaxInt32)},
+		{"uint32_overflow", uint32(math.MaxInt32) + 1, nil},
+		{"uint", uint(math.MaxInt32), int32(math.MaxInt32)},
+		{"uint64", uint64(math.MaxInt64), int64(math.MaxInt64)},
+		{"uint64_overflow", uint64(math.MaxInt64) + 1, nil},
+		{"uintptr", uintptr(1), nil},
+	}
+	if strconv.IntSize == 64 {
+		// Values beyond the range of int32, which int and uint can hold
+		big, small := uint64(math.MaxInt32)+1, int64(math.MinInt32)-1
+		tests = append(tests,
+			test{"int_overflow", int(big), nil},
+			test{"int_underflow", int(small), nil},
+			test{"uint_overflow", uint(big), nil},
+		)
+	}
+	for _, tt := range tests {
+		msg := NewMessage("/a")
+		msg.Append(tt.arg)
+		data, err := msg.MarshalBinary()
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: MarshalBinary() expected an error", tt.desc)
+			}
+			if _, err := msg.TypeTags(); err == nil {
+				t.Errorf("%s: TypeTags() expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: MarshalBinary() error = %v", tt.desc, err)
+			continue
+		}
+		p, err := ParsePacket(string(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if got := p.(*Message).Arguments[0]; got != tt.want {
+			t.Errorf("%s: decoded %T(%v), want = %T(%v)", tt.desc, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestMessage_CountArgumentsOfType(t *testing.T) {
+	msg := NewMessage("/a", float32(1), int32(2), float32(3), 4, true, false, true,
+		[]interface{}{float32(5)}, struct{}{}, float32(6))
+	for _, tt := range []struct {
+		tag  byte
+		want int
+	}{
+		{'f', 3},
+		{'i', 2},
+		{'T', 2},
+		{'F', 1},
+		{'[', 1},
+		{']', 0},
+		{'s', 0},
+	} {
+		if got := msg.CountArgumentsOfType(tt.tag); got != tt.want {
+			t.Errorf("CountArgumentsOfType(%q) = %d, want = %d", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestMessage_Range(t *testing.T) {
+	msg := NewMessage("/address", int32(1), 2, "three", []byte{4}, true, nil, float64(5))
+	var tags []byte
+	var args []interface{}
+	if err := msg.Range(func(tag byte, v interface{}) bool {
+		tags = append(tags, tag)
+		args = append(args, v)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := msg.TypeTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := "," + string(tags); got != want {
+		t.Errorf("tags = %q, want = %q", got, want)
+	}
+	if !reflect.DeepEqual(args, msg.Arguments) {
+		t.Errorf("args = %v, want = %v", args, msg.Arguments)
+	}
+
+	n := 0
+	if err := msg.Range(func(tag byte, v interface{}) bool {
+		n++
+		return n < 2
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("fn called %d times after returning false, want = 2", n)
+	}
+
+	if err := NewMessage("/address", int32(1), struct{}{}).Range(func(tag byte, v interface{}) bool {
+		t.Error("fn called for message with unsupported argument")
+		return true
+	}); err == nil {
+		t.Error("expected error for unsupported argument")
+	}
+}
+
 func TestMessage_String(t *testing.T) {
 	for _, tt := range []struct {
 		desc string
@@ -108,9 +666,81 @@ func TestAddMsgHandler(t *testing.T) {
 
 func TestAddMsgHandlerWithInvalidAddress(t *testing.T) {
 	d := NewStandardDispatcher()
-	err := d.AddMsgHandler("/address*/test", func(msg *Message) {})
-	if err == nil {
-		t.Error("Expected error with '/address*/test'")
+	for _, c := range []byte(" #*,?[]{}\x00\x01\t\n\x1f\x7f") {
+		addr := "/address" + string(c) + "/test"
+		err := d.AddMsgHandler(addr, func(msg *Message) {})
+		if err == nil {
+			t.Errorf("Expected error with %q", addr)
+			continue
+		}
+		if want := fmt.Sprintf("%q at offset 8", c); !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error %q should name the character as %s", addr, err, want)
+		}
+	}
+	for _, addr := range []string{"/address//test", "/address/test/"} {
+		if err := d.AddMsgHandler(addr, func(msg *Message) {}); err == nil {
+			t.Errorf("Expected error with %q", addr)
+		}
+	}
+	if got := d.Addresses(); len(got) != 0 {
+		t.Errorf("Addresses() = %v, want none", got)
+	}
+}
+
+// countingHandler is a stateful Handler counting the messages it got.
+type countingHandler struct {
+	count int
+}
+
+func (h *countingHandler) HandleMessage(msg *Message) {
+	h.count++
+}
+
+// dispatcherFunc is a Dispatcher without handler registration.
+type dispatcherFunc func(packet Packet)
+
+func (f dispatcherFunc) Dispatch(packet Packet) {
+	f(packet)
+}
+
+func TestServerHandle(t *testing.T) {
+	for _, tt := range []struct {
+		desc       string
+		dispatcher Dispatcher
+	}{
+		{"default", nil},
+		{"standard", NewStandardDispatcher()},
+		{"mux", NewServeMux()},
+	} {
+		server := &Server{Dispatcher: tt.dispatcher}
+		h := &countingHandler{}
+		if err := server.Handle("/count", h); err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		called := false
+		if err := server.HandleFunc("/func", func(msg *Message) {
+			called = true
+		}); err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if err := server.Handle("/count", h); err == nil {
+			t.Errorf("%s: expected error adding a handler for an existing address", tt.desc)
+		}
+
+		server.Dispatcher.Dispatch(NewMessage("/count"))
+		server.Dispatcher.Dispatch(NewMessage("/count"))
+		server.Dispatcher.Dispatch(NewMessage("/func"))
+		if h.count != 2 {
+			t.Errorf("%s: count = %d, want = 2", tt.desc, h.count)
+		}
+		if !called {
+			t.Errorf("%s: handler function wasn't called", tt.desc)
+		}
+	}
+
+	server := &Server{Dispatcher: dispatcherFunc(func(Packet) {})}
+	if err := server.Handle("/a", &countingHandler{}); err == nil {
+		t.Error("expected error registering a handler with a custom dispatcher")
 	}
 }
 
@@ -205,6 +835,49 @@ func TestServerMessageDispatching(t *testing.T) {
 	done.Wait()
 }
 
+func TestServerConcurrentHandlerRegistration(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewStandardDispatcher()
+	server := &Server{Dispatcher: d}
+	served := make(chan error)
+	go func() {
+		served <- server.Serve(conn)
+	}()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	received := make(chan bool, 100)
+	for i := 0; i < 20; i++ {
+		addr := "/address/" + strconv.Itoa(i)
+		if err := d.AddMsgHandler(addr, func(msg *Message) {
+			received <- true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Send(NewMessage("/address/*")); err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			if err := d.RemoveMsgHandler(addr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = d.Addresses()
+	}
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Error("no handler was called")
+	}
+
+	conn.Close()
+	<-served
+}
+
 func TestServerMessageReceiving(t *testing.T) {
 	port := 6677
 
@@ -282,15 +955,309 @@ func TestServerMessageReceiving(t *testing.T) {
 	done.Wait()
 }
 
-func TestReadTimeout(t *testing.T) {
-	start := make(chan bool)
-	wg := sync.WaitGroup{}
-	wg.Add(2)
+func TestServerServeOne(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
 
-	go func() {
-		defer wg.Done()
+	d := NewStandardDispatcher()
+	var received *Message
+	if err := d.AddMsgHandler("/address/test", func(msg *Message) {
+		received = msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
 
-		select {
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	if err := client.Send(NewMessage("/address/test", int32(1122))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler has been called when ServeOne returns.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.ServeOne(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	if received == nil {
+		t.Fatal("handler wasn't called")
+	}
+	if got, want := received.Arguments[0], int32(1122); got != want {
+		t.Errorf("Argument should be %d and is: %v", want, got)
+	}
+
+	// Without a packet, the context ends the wait.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.ServeOne(ctx, conn); err != context.DeadlineExceeded {
+		t.Errorf("ServeOne() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+
+	// The connection is still usable afterwards.
+	if err := client.Send(NewMessage("/address/test", int32(3344))); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.ServeOne(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := received.Arguments[0], int32(3344); got != want {
+		t.Errorf("Argument should be %d and is: %v", want, got)
+	}
+}
+
+func TestServerReadBufferSize(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	small := NewMessage("/small") // 12 bytes
+	large := NewMessage("/large", make([]byte, 100))
+	for _, p := range []Packet{small, large, small} {
+		if err := client.Send(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := &Server{ReadTimeout: time.Second, ReadBufferSize: 16}
+	if _, err := server.ReceivePacket(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ReceivePacket(conn); err != ErrPacketTruncated {
+		t.Errorf("ReceivePacket() error = %v, want = %v", err, ErrPacketTruncated)
+	}
+	if _, err := server.ReceivePacket(conn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerBundleSourceAddress(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	d := NewStandardDispatcher()
+	sources := make(chan net.Addr, 2)
+	if err := d.AddMsgHandlerFrom("/a", func(msg *Message, addr net.Addr) {
+		sources <- addr
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	port := freeUDPPort(t)
+	if err := client.SetLocalAddr("127.0.0.1", port); err != nil {
+		t.Fatal(err)
+	}
+	nested := NewBundle(time.Now())
+	nested.Append(NewMessage("/a"))
+	bundle := NewBundle(time.Now())
+	bundle.Append(NewMessage("/a"))
+	bundle.Append(nested)
+	if err := client.Send(bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.ServeOne(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case addr := <-sources:
+			if got, want := addr.String(), fmt.Sprintf("127.0.0.1:%d", port); got != want {
+				t.Errorf("source address = %s, want = %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler wasn't called")
+		}
+	}
+}
+
+func TestServerFramedPackets(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var datagram []byte
+	for _, addr := range []string{"/first", "/second"} {
+		b, err := NewMessage(addr, int32(1)).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := make([]byte, 4)
+		binary.BigEndian.PutUint32(size, uint32(len(b)))
+		datagram = append(datagram, size...)
+		datagram = append(datagram, b...)
+	}
+
+	sender, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write(datagram); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{ReadTimeout: time.Second, FramedPackets: true}
+	for _, want := range []string{"/first", "/second"} {
+		p, err := server.ReceivePacket(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.(*Message).Address; got != want {
+			t.Errorf("wrong address; got = %s, want = %s", got, want)
+		}
+	}
+
+	// The packets left over from a datagram are only returned for the
+	// connection it was received on
+	other, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := sender.Write(datagram); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := framePacket(NewMessage("/other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.WriteTo(frame, other.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		conn net.PacketConn
+		want string
+	}{
+		{conn, "/first"},
+		{other, "/other"},
+		{conn, "/second"},
+	} {
+		p, err := server.ReceivePacket(tt.conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.(*Message).Address; got != tt.want {
+			t.Errorf("wrong address; got = %s, want = %s", got, tt.want)
+		}
+	}
+}
+
+func TestClientFramedPackets(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	client.SetFramedPackets(true)
+	if err := client.Send(NewMessage("/framed", int32(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{ReadTimeout: time.Second, FramedPackets: true}
+	p, err := server.ReceivePacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.(*Message).Address, "/framed"; got != want {
+		t.Errorf("wrong address; got = %s, want = %s", got, want)
+	}
+}
+
+func TestClientPersistent(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	client.SetPersistent(true)
+	defer client.Close()
+	server := &Server{ReadTimeout: time.Second}
+	receive := func() string {
+		t.Helper()
+		_, addr, err := server.ReceivePacketFrom(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addr.String()
+	}
+
+	// All packets are sent on the same connection, i.e. from the same port
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		if err := client.Send(NewMessage("/a", int32(i))); err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, receive())
+	}
+	if addrs[1] != addrs[0] || addrs[2] != addrs[0] {
+		t.Errorf("packets sent from %v, want the same address", addrs)
+	}
+	kept := client.conn
+
+	// Close closes the connection, and the next send opens a new one
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendRaw(mustMarshal(t, NewMessage("/b"))); err != nil {
+		t.Fatal(err)
+	}
+	receive()
+	if client.conn == nil || client.conn == kept {
+		t.Error("no new connection opened after Close")
+	}
+
+	client.SetPersistent(false)
+	if client.conn != nil {
+		t.Error("connection kept after disabling SetPersistent")
+	}
+}
+
+func TestReadFramedPackets(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short_size", []byte{0, 0}},
+		{"zero_size", []byte{0, 0, 0, 0}},
+		{"size_too_large", []byte{0, 0, 0, 8, '/', 'a', 0, 0}},
+	} {
+		if _, err := readFramedPackets(tt.data, ParseOptions{}, false); err == nil {
+			t.Errorf("%s: readFramedPackets() expected an error", tt.desc)
+		}
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	start := make(chan bool)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		select {
 		case <-time.After(5 * time.Second):
 			t.Error("timed out")
 			wg.Done()
@@ -354,6 +1321,332 @@ func TestReadTimeout(t *testing.T) {
 	wg.Wait()
 }
 
+func TestServeContextTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	received := make(chan bool, 1)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/late", func(msg *Message) {
+		received <- true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d, ReadTimeout: 10 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeContext(ctx, conn)
+	}()
+
+	// Serve must survive several read timeouts
+	time.Sleep(50 * time.Millisecond)
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	if err := client.Send(NewMessage("/late")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("ServeContext() error = %v, want = %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeContext() didn't return after cancel")
+	}
+}
+
+func TestServerServeAll(t *testing.T) {
+	var conns []net.PacketConn
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+
+	received := make(chan string, len(conns))
+	server := &Server{}
+	if err := server.HandleFunc("*", func(msg *Message) {
+		received <- msg.Address
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeAll(ctx, conns...)
+	}()
+
+	want := map[string]bool{}
+	for i, conn := range conns {
+		addr := fmt.Sprintf("/conn%d", i)
+		want[addr] = true
+		client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+		if err := client.Send(NewMessage(addr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range conns {
+		select {
+		case addr := <-received:
+			if !want[addr] {
+				t.Errorf("received unexpected message %s", addr)
+			}
+			delete(want, addr)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("ServeAll() error = %v, want = %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeAll() didn't return after cancel")
+	}
+}
+
+func TestServerServeAllDefaultDispatcher(t *testing.T) {
+	var conns []net.PacketConn
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+
+	server := &Server{}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeAll(ctx, conns...)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); len(server.LocalAddrs()) < len(conns); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the server to serve")
+		}
+	}
+	cancel()
+	select {
+	case <-errc:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeAll() didn't return after cancel")
+	}
+	if server.Dispatcher == nil {
+		t.Error("ServeAll() didn't set a default Dispatcher")
+	}
+}
+
+func TestServerListenAndServeAll(t *testing.T) {
+	server := &Server{Dispatcher: NewStandardDispatcher()}
+	if err := server.ListenAndServeAll(); err == nil {
+		t.Error("expected an error without addresses")
+	}
+	if err := server.ListenAndServeAll("127.0.0.1:0", "invalid:address"); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+func TestServerLocalAddr(t *testing.T) {
+	received := make(chan bool, 1)
+	server := &Server{}
+	if err := server.HandleFunc("/port", func(msg *Message) {
+		received <- true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if addr := server.LocalAddr(); addr != nil {
+		t.Errorf("LocalAddr() = %v before serving, want = nil", addr)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServeAll("127.0.0.1:0", "127.0.0.1:0")
+	}()
+
+	var addr net.Addr
+	for deadline := time.Now().Add(5 * time.Second); addr == nil || len(server.LocalAddrs()) < 2; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the server to serve")
+		}
+		addr = server.LocalAddr()
+	}
+	port := addr.(*net.UDPAddr).Port
+	if port == 0 {
+		t.Fatal("LocalAddr() returned port 0")
+	}
+	if addrs := server.LocalAddrs(); addrs[0].String() == addrs[1].String() {
+		t.Errorf("LocalAddrs() = %v, want different addresses", addrs)
+	}
+	if err := NewClient("127.0.0.1", port).Send(NewMessage("/port")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	server.CloseConnection()
+	<-errc
+	if addr := server.LocalAddr(); addr != nil {
+		t.Errorf("LocalAddr() = %v after serving, want = nil", addr)
+	}
+}
+
+func TestServerReusePort(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("SO_REUSEPORT isn't supported on this platform")
+	}
+	server := &Server{ReusePort: true}
+	first, err := server.listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := server.listen(first.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("listening on the same port again: %v", err)
+	}
+	second.Close()
+
+	server.ReusePort = false
+	if conn, err := server.listen(first.LocalAddr().String()); err == nil {
+		conn.Close()
+		t.Error("expected an error listening on the same port without ReusePort")
+	}
+}
+
+// fakeReader is a PacketReader returning the given datagrams.
+type fakeReader struct {
+	datagrams [][]byte
+}
+
+func (r *fakeReader) ReadFrom(p []byte) (int, net.Addr, error) {
+	if len(r.datagrams) == 0 {
+		return 0, nil, io.EOF
+	}
+	n := copy(p, r.datagrams[0])
+	r.datagrams = r.datagrams[1:]
+	return n, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 9000}, nil
+}
+
+func (r *fakeReader) SetReadDeadline(t time.Time) error { return nil }
+
+func TestReceivePacketFromReader(t *testing.T) {
+	msg := NewMessage("/fake", int32(1))
+	r := &fakeReader{datagrams: [][]byte{mustMarshal(t, msg)}}
+	server := &Server{ReadTimeout: time.Second}
+	p, addr, err := server.ReceivePacketFrom(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.(*Message).Equals(msg) {
+		t.Errorf("ReceivePacketFrom() = %v, want = %v", p, msg)
+	}
+	if got, want := addr.String(), "192.0.2.1:9000"; got != want {
+		t.Errorf("addr = %s, want = %s", got, want)
+	}
+	if _, err := server.ReceivePacket(r); err != io.EOF {
+		t.Errorf("ReceivePacket() error = %v, want = %v", err, io.EOF)
+	}
+}
+
+func TestServerOnRawPacket(t *testing.T) {
+	valid := mustMarshal(t, NewMessage("/valid"))
+	long := mustMarshal(t, NewMessage("/a/long/address/exceeding/the/buffer"))
+	r := &fakeReader{datagrams: [][]byte{valid, []byte("invalid"), long}}
+	var got [][]byte
+	server := &Server{
+		ReadBufferSize: 32,
+		OnRawPacket: func(data []byte, addr net.Addr) {
+			got = append(got, data)
+		},
+	}
+	for i := 0; i < 3; i++ {
+		_, err := server.ReceivePacket(r)
+		if ok := i == 0; ok != (err == nil) {
+			t.Errorf("datagram %d: ReceivePacket() error = %v", i, err)
+		}
+	}
+	want := [][]byte{valid, []byte("invalid"), long[:32]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnRawPacket() called with %q, want = %q", got, want)
+	}
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestServerLogger(t *testing.T) {
+	// A nil logger must be silently ignored.
+	server := &Server{}
+	server.logf("should not panic: %d", 1)
+
+	logger := &recordingLogger{}
+	server.Logger = logger
+	server.logf("hello %s", "world")
+	if got, want := logger.lines, []string{"hello world"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("logged lines = %q, want = %q", got, want)
+	}
+}
+
+func TestServerMulticast(t *testing.T) {
+	server := &Server{}
+	if err := server.JoinMulticast("127.0.0.1", nil); err == nil {
+		t.Error("Expected error joining a unicast address")
+	}
+	if err := server.LeaveMulticast("239.255.0.1", nil); err == nil {
+		t.Error("Expected error leaving a group that wasn't joined")
+	}
+
+	// Groups joined before serving are remembered until the server serves.
+	if err := server.JoinMulticast("239.255.0.1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.JoinMulticast("239.255.0.1", nil); err == nil {
+		t.Error("Expected error joining the same group twice")
+	}
+
+	conn, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := server.addConn(conn); err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	if err := server.JoinMulticast("239.255.0.2", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range []string{"239.255.0.1", "239.255.0.2"} {
+		if err := server.LeaveMulticast(group, nil); err != nil {
+			t.Errorf("LeaveMulticast(%s): %v", group, err)
+		}
+	}
+}
+
 func TestReadBlob(t *testing.T) {
 	for _, tt := range []struct {
 		name    string
@@ -365,6 +1658,7 @@ func TestReadBlob(t *testing.T) {
 		{"negative value", []byte{255, 255, 255, 255}, nil, 0, true},
 		{"large value", []byte{0, 1, 17, 112}, nil, 0, true},
 		{"regular value", []byte{0, 0, 0, 1, 10, 0, 0, 0}, []byte{10}, 8, false},
+		{"empty", []byte{0, 0, 0, 0}, []byte{}, 4, false},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			got, got1, err := readBlob(bufio.NewReader(bytes.NewBuffer(tt.args)))
@@ -514,6 +1808,57 @@ func TestTypeTagsString(t *testing.T) {
 	}
 }
 
+func TestTypeTagsArray(t *testing.T) {
+	for _, tt := range []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{[]interface{}{int32(1), int32(2)}, "s"}, ",[ii]s"},
+		{[]interface{}{[]interface{}{}}, ",[]"},
+		{[]interface{}{float32(1), []interface{}{true, []interface{}{"a"}}}, ",f[T[s]]"},
+	} {
+		msg := NewMessage("/some/address", tt.args...)
+		typeTags, err := msg.TypeTags()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typeTags != tt.want {
+			t.Errorf("TypeTags() = %q, want = %q", typeTags, tt.want)
+		}
+
+		p, err := ParsePacket(string(mustMarshal(t, msg)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.(*Message).Equals(msg) {
+			t.Errorf("ParsePacket() = %v, want = %v", p, msg)
+		}
+	}
+
+	for _, typetags := range []string{",i]", ",[i", ",[[i]"} {
+		data := "/a" + nulls(2) + typetags + nulls(4-len(typetags)%4) + "\x00\x00\x00\x01"
+		if _, err := ParsePacket(data); err == nil {
+			t.Errorf("ParsePacket() with type tags %q expected an error", typetags)
+		}
+	}
+}
+
+func TestTypeTagsBytes(t *testing.T) {
+	msg := NewMessage("/some/address", int32(100), "foo", nil)
+	tags, err := msg.TypeTagsBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(tags), ",isN"; got != want {
+		t.Errorf("TypeTagsBytes() = %q, want = %q", got, want)
+	}
+
+	var nilMsg *Message
+	if _, err := nilMsg.TypeTagsBytes(); err == nil {
+		t.Error("Expected error for nil message")
+	}
+}
+
 func TestClientSetLocalAddr(t *testing.T) {
 	client := NewClient("localhost", 8967)
 	err := client.SetLocalAddr("localhost", 41789)
@@ -526,6 +1871,311 @@ func TestClientSetLocalAddr(t *testing.T) {
 	}
 }
 
+func TestClientIPv6(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 isn't available: %v", err)
+	}
+	defer conn.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	var from net.Addr
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandlerFrom("/ipv6", func(msg *Message, addr net.Addr) {
+		from = addr
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
+
+	for _, ip := range []string{"::1", "[::1]"} {
+		from = nil
+		client := NewClient(ip, port)
+		if err := client.SetLocalAddr(ip, 0); err != nil {
+			t.Fatal(err)
+		}
+		if got := client.laddr.IP.String(); got != "::1" {
+			t.Errorf("%s: laddr = %s, want = ::1", ip, got)
+		}
+		if err := client.Send(NewMessage("/ipv6")); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.ServeOne(context.Background(), conn); err != nil {
+			t.Fatal(err)
+		}
+		if ua, ok := from.(*net.UDPAddr); !ok || !ua.IP.Equal(net.IPv6loopback) {
+			t.Errorf("%s: received from %v, want = [::1]", ip, from)
+		}
+	}
+}
+
+func TestClientSetBroadcast(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	if err := client.SetLocalAddr("127.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetBroadcast(true); err != nil {
+		t.Fatal(err)
+	}
+	if !client.Broadcast() {
+		t.Error("Expected broadcast to be enabled")
+	}
+	if err := client.Send(NewMessage("/broadcast")); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{ReadTimeout: time.Second}
+	p, err := server.ReceivePacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.(*Message).Address, "/broadcast"; got != want {
+		t.Errorf("wrong address; got = %s, want = %s", got, want)
+	}
+
+	// Send to the limited broadcast address, if the network allows it
+	bconn, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bconn.Close()
+	client = NewClient("255.255.255.255", bconn.LocalAddr().(*net.UDPAddr).Port)
+	if err := client.SetBroadcast(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(NewMessage("/broadcast")); err != nil {
+		t.Skipf("broadcast isn't available: %v", err)
+	}
+	p, err = server.ReceivePacket(bconn)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Skip("broadcast packet wasn't received")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.(*Message).Address, "/broadcast"; got != want {
+		t.Errorf("wrong address; got = %s, want = %s", got, want)
+	}
+}
+
+func TestClientSendRaw(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data, err := NewMessage("/heartbeat", int32(1)).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	server := &Server{ReadTimeout: time.Second}
+	for i := 0; i < 2; i++ {
+		if err := client.SendRaw(data); err != nil {
+			t.Fatal(err)
+		}
+		p, err := server.ReceivePacket(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := p.(*Message).Address, "/heartbeat"; got != want {
+			t.Errorf("wrong address; got = %s, want = %s", got, want)
+		}
+	}
+}
+
+func TestClientSendRetries(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	client.SetSendRetries(2, 10*time.Millisecond)
+	if n, interval := client.SendRetries(); n != 2 || interval != 10*time.Millisecond {
+		t.Errorf("SendRetries() = %d, %v, want = 2, 10ms", n, interval)
+	}
+	msg := NewMessage("/retry", int32(1))
+	if err := client.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{}
+	for i := 0; i < 3; i++ {
+		if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		p, err := server.ReceivePacket(conn)
+		if err != nil {
+			t.Fatalf("packet %d: %s", i, err)
+		}
+		if !p.(*Message).Equals(msg) {
+			t.Errorf("packet %d = %v, want = %v", i, p, msg)
+		}
+	}
+
+	// Retransmissions are canceled with the context
+	client.SetSendRetries(5, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := client.SendContext(ctx, msg); err != context.DeadlineExceeded {
+		t.Errorf("SendContext() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+	if err := client.SendContext(ctx, msg); err != context.DeadlineExceeded {
+		t.Errorf("SendContext() with done context error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSocketBuffers(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	received := make(chan bool, 1)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/buf", func(msg *Message) {
+		received <- true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d, SocketReadBuffer: 1 << 20}
+	go server.Serve(conn)
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	client.SetWriteBuffer(1 << 20)
+	if got, want := client.WriteBuffer(), 1<<20; got != want {
+		t.Errorf("WriteBuffer() = %d, want = %d", got, want)
+	}
+	if err := client.Send(NewMessage("/buf")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// Connections without buffer settings are rejected
+	wrapped := struct{ net.PacketConn }{conn}
+	if err := server.Serve(wrapped); err == nil {
+		t.Error("expected error for connection without SetReadBuffer")
+	}
+}
+
+func TestClientTypeTagMode(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	if got, want := client.TypeTagMode(), TypeTagsOSC11; got != want {
+		t.Errorf("TypeTagMode() = %d, want = %d", got, want)
+	}
+	client.SetTypeTagMode(TypeTagsOSC10)
+
+	bundle := NewBundle(time.Now())
+	bundle.Append(NewMessage("/bools", true, false, "s"))
+	orig := NewMessage("/bools", true)
+	for _, p := range []Packet{orig, bundle} {
+		if err := client.Send(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Send(NewMessage("/nil", nil)); err == nil {
+		t.Error("Expected error sending nil with OSC 1.0 type tags")
+	}
+
+	server := &Server{ReadTimeout: time.Second}
+	p, err := server.ReceivePacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.(*Message).Arguments, []interface{}{int32(1)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Arguments = %#v, want = %#v", got, want)
+	}
+	if got, want := orig.Arguments[0], true; got != want {
+		t.Error("Send must not modify the message")
+	}
+
+	p, err = server.ReceivePacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.(*Bundle).Messages[0].Arguments
+	if want := []interface{}{int32(1), int32(0), "s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Arguments = %#v, want = %#v", got, want)
+	}
+}
+
+func TestClientSetInterface(t *testing.T) {
+	client := NewClient("127.0.0.1", 8967)
+	if err := client.SetInterface(&net.Interface{Index: 1 << 30, Name: "missing"}); err == nil {
+		t.Error("Expected error for an interface without addresses")
+	}
+	if client.Interface() != nil {
+		t.Error("Interface shouldn't be set after an error")
+	}
+
+	var loopback *net.Interface
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagLoopback != 0 {
+			loopback = &ifaces[i]
+			break
+		}
+	}
+	if loopback == nil {
+		t.Skip("no loopback interface")
+	}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client.SetPort(conn.LocalAddr().(*net.UDPAddr).Port)
+	if err := client.SetInterface(loopback); err != nil {
+		t.Fatal(err)
+	}
+	// The port of the local address is combined with the interface address.
+	port := freeUDPPort(t)
+	if err := client.SetLocalAddr("0.0.0.0", port); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(NewMessage("/interface")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	_, from, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := from.String(), fmt.Sprintf("127.0.0.1:%d", port); got != want {
+		t.Errorf("source address = %s, want = %s", got, want)
+	}
+}
+
 func TestParsePacket(t *testing.T) {
 	for _, tt := range []struct {
 		desc string
@@ -542,6 +2192,8 @@ func TestParsePacket(t *testing.T) {
 			makePacket("/d/e/f", []string{"foo"}),
 			true},
 		{"empty", "", nil, false},
+		{"too_short", "/a", nil, false},
+		{"invalid", "abc" + nulls(1), nil, false},
 	} {
 		pkt, err := ParsePacket(tt.msg)
 		if err != nil && tt.ok {
@@ -571,6 +2223,834 @@ func TestParsePacket(t *testing.T) {
 	}
 }
 
+func TestMessageNoArguments(t *testing.T) {
+	msg := NewMessage("/a/b/c")
+	tags, err := msg.TypeTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags != "," {
+		t.Errorf("TypeTags() = %q, want = %q", tags, ",")
+	}
+
+	data := mustMarshal(t, msg)
+	if want := "/a/b/c" + nulls(2) + "," + nulls(3); string(data) != want {
+		t.Errorf("MarshalBinary() = %q, want = %q", data, want)
+	}
+
+	p, err := ParsePacket(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.(*Message)
+	if got.Address != msg.Address || len(got.Arguments) != 0 {
+		t.Errorf("ParsePacket() = %v, want = %v", got, msg)
+	}
+	if !got.Equals(msg) {
+		t.Errorf("ParsePacket() = %#v, want = %#v", got, msg)
+	}
+}
+
+func TestParseMissingTypeTags(t *testing.T) {
+	// A message without type tag string, alone and followed by another
+	// element of a bundle
+	msg := "/no/tags" + nulls(4)
+	bundle := "#bundle" + nulls(1) + nulls(7) + "\x01" +
+		"\x00\x00\x00\x0c" + msg +
+		"\x00\x00\x00\x08" + "/b" + nulls(2) + "," + nulls(3)
+
+	for _, data := range []string{msg, bundle} {
+		if _, err := ParsePacket(data); err == nil {
+			t.Errorf("ParsePacket(%q) expected an error", data)
+		}
+
+		p, err := ParseOptions{AllowMissingTypeTags: true}.Parse([]byte(data))
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", data, err)
+		}
+		if b, ok := p.(*Bundle); ok {
+			if len(b.Messages) != 2 || b.Messages[1].Address != "/b" {
+				t.Fatalf("Parse(%q) = %v, want = 2 messages", data, b.Messages)
+			}
+			p = b.Messages[0]
+		}
+		if got := p.(*Message); got.Address != "/no/tags" || len(got.Arguments) != 0 {
+			t.Errorf("Parse(%q) = %v, want = /no/tags without arguments", data, got)
+		}
+	}
+
+	// The server parses with its options
+	client, conn := Pipe()
+	defer conn.Close()
+	server := &Server{ParseOptions: ParseOptions{AllowMissingTypeTags: true}}
+	if err := client.SendRaw([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ReceivePacket(conn); err != nil {
+		t.Errorf("ReceivePacket() error = %v", err)
+	}
+}
+
+func TestParseTruncatedArguments(t *testing.T) {
+	lenient := ParseOptions{AllowTruncatedArguments: true}
+	for _, tt := range []struct {
+		name string
+		data string
+		want []interface{}
+	}{
+		{"missing int32", "/a\x00\x00,ii\x00\x00\x00\x00\x01", []interface{}{int32(1)}},
+		{"short int32", "/a\x00\x00,ii\x00\x00\x00\x00\x01\x00\x00", []interface{}{int32(1)}},
+		{"unterminated string", "/a\x00\x00,is\x00\x00\x00\x00\x01abcd", []interface{}{int32(1)}},
+		{"short blob", "/a\x00\x00,b\x00\x00\x00\x00\x00\x08abcd", nil},
+		{"open array", "/a\x00\x00,i[ii]\x00\x00\x00\x00\x00\x01\x00\x00\x00\x02",
+			[]interface{}{int32(1), []interface{}{int32(2)}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePacket(tt.data); !errors.Is(err, ErrShortPacket) {
+				t.Errorf("ParsePacket() error = %v, want ErrShortPacket", err)
+			}
+
+			p, err := lenient.Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := p.(*Message)
+			if !msg.Truncated() {
+				t.Error("Truncated() = false, want = true")
+			}
+			if !reflect.DeepEqual(msg.Arguments, tt.want) {
+				t.Errorf("Arguments = %#v, want = %#v", msg.Arguments, tt.want)
+			}
+		})
+	}
+
+	// A truncated message in a bundle doesn't extend into the next element
+	truncated := "/a\x00\x00,ii\x00\x00\x00\x00\x01"
+	next := string(mustMarshal(t, NewMessage("/b", int32(2))))
+	data := "#bundle\x00" + string(nulls(7)) + "\x01" +
+		"\x00\x00\x00\x0c" + truncated +
+		"\x00\x00\x00" + string(rune(len(next))) + next
+	p, err := lenient.Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := p.(*Bundle)
+	if len(b.Messages) != 2 || !b.Messages[0].Truncated() || b.Messages[1].Truncated() {
+		t.Fatalf("Parse() = %v, want a truncated and a complete message", b)
+	}
+	if got := b.Messages[1].Arguments; !reflect.DeepEqual(got, []interface{}{int32(2)}) {
+		t.Errorf("Arguments = %v, want = [2]", got)
+	}
+
+	// The server counts truncated messages
+	client, conn := Pipe()
+	defer conn.Close()
+	server := &Server{ParseOptions: lenient}
+	if err := client.SendRaw([]byte(truncated)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ReceivePacket(conn); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Stats().TruncatedMessages; got != 1 {
+		t.Errorf("TruncatedMessages = %d, want = 1", got)
+	}
+}
+
+func TestParseLittleEndianNumbers(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("/a\x00\x00,ihfds\x00\x00")
+	for _, v := range []interface{}{int32(1), int64(-2), float32(0.5), float64(1.25)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("ab\x00\x00")
+
+	opts := ParseOptions{LittleEndianNumbers: true}
+	p, err := opts.Parse(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewMessage("/a", int32(1), int64(-2), float32(0.5), float64(1.25), "ab")
+	if got := p.(*Message); !got.Equals(want) {
+		t.Errorf("Parse() = %v, want = %v", got, want)
+	}
+
+	// Packets are parsed as big-endian by default
+	p, err = ParsePacket(b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.(*Message).Arguments[0]; got != int32(1<<24) {
+		t.Errorf("ParsePacket() argument = %v, want = %v", got, 1<<24)
+	}
+}
+
+func TestParseMaxArguments(t *testing.T) {
+	nils := make([]interface{}, DefaultMaxArguments+1)
+	for _, tt := range []struct {
+		opts    ParseOptions
+		msg     *Message
+		wantErr bool
+	}{
+		{ParseOptions{}, NewMessage("/a", nils[1:]...), false},
+		{ParseOptions{}, NewMessage("/a", nils...), true},
+		{ParseOptions{MaxArguments: 3}, NewMessage("/a", int32(1), "b", true), false},
+		{ParseOptions{MaxArguments: 3}, NewMessage("/a", int32(1), "b", true, nil), true},
+		{ParseOptions{MaxArguments: 3}, NewMessage("/a", []interface{}{int32(1), int32(2)}, true), false},
+		{ParseOptions{MaxArguments: 3}, NewMessage("/a", []interface{}{int32(1), []interface{}{int32(2), int32(3)}}, true), true},
+	} {
+		_, err := tt.opts.Parse(mustMarshal(t, tt.msg))
+		if tt.wantErr != errors.Is(err, ErrTooManyArguments) {
+			t.Errorf("Parse(%d arguments) with MaxArguments %d error = %v, wantErr = %v",
+				len(tt.msg.Arguments), tt.opts.MaxArguments, err, tt.wantErr)
+		}
+	}
+}
+
+// nestedBundles returns depth bundles nested in each other around a message,
+// built without recursion.
+func nestedBundles(t *testing.T, depth int) []byte {
+	data := mustMarshal(t, NewMessage("/a"))
+	for i := 0; i < depth; i++ {
+		var b bytes.Buffer
+		b.WriteString("#bundle\x00")
+		binary.Write(&b, binary.BigEndian, uint64(1))
+		binary.Write(&b, binary.BigEndian, int32(len(data)))
+		b.Write(data)
+		data = b.Bytes()
+	}
+	return data
+}
+
+func TestParseBundleDepth(t *testing.T) {
+	for _, tt := range []struct {
+		opts    ParseOptions
+		depth   int
+		wantErr bool
+	}{
+		{ParseOptions{}, DefaultMaxBundleDepth, false},
+		{ParseOptions{}, DefaultMaxBundleDepth + 1, true},
+		{ParseOptions{}, 1000, true},
+		{ParseOptions{MaxBundleDepth: 2}, 2, false},
+		{ParseOptions{MaxBundleDepth: 2}, 3, true},
+	} {
+		_, err := tt.opts.Parse(nestedBundles(t, tt.depth))
+		if tt.wantErr != errors.Is(err, ErrBundleTooDeep) {
+			t.Errorf("Parse(depth %d) with MaxBundleDepth %d error = %v, wantErr = %v",
+				tt.depth, tt.opts.MaxBundleDepth, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPacket) {
+			t.Errorf("Parse(depth %d) error = %v, want ErrInvalidPacket", tt.depth, err)
+		}
+	}
+
+	// The server drops bundles built in memory that are nested too deeply
+	bundle := NewBundle(time.Time{})
+	if err := bundle.Append(NewMessage("/a")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 3; i++ {
+		outer := NewBundle(time.Time{})
+		if err := outer.Append(bundle); err != nil {
+			t.Fatal(err)
+		}
+		bundle = outer
+	}
+	var called bool
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/a", func(msg *Message) { called = true }); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d, ParseOptions: ParseOptions{MaxBundleDepth: 2}}
+	server.dispatch(bundle, nil)
+	if called {
+		t.Error("handler called for a bundle nested too deeply")
+	}
+	server.ParseOptions.MaxBundleDepth = 3
+	server.dispatch(bundle, nil)
+	if !called {
+		t.Error("handler not called")
+	}
+}
+
+func TestParsePacketRaw(t *testing.T) {
+	msg1 := NewMessage("/a", float32(0.1), "x")
+	msg2 := NewMessage("/b/c", int64(2))
+	nested := NewBundle(time.Now())
+	if err := nested.Append(msg2); err != nil {
+		t.Fatal(err)
+	}
+	bundle := NewBundle(time.Now())
+	if err := bundle.Append(msg1, nested); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ParsePacketRaw(mustMarshal(t, bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := p.(*Bundle)
+	if got, want := b.Messages[0].Raw(), mustMarshal(t, msg1); !bytes.Equal(got, want) {
+		t.Errorf("Raw() = %v, want = %v", got, want)
+	}
+	if got, want := b.Bundles[0].Messages[0].Raw(), mustMarshal(t, msg2); !bytes.Equal(got, want) {
+		t.Errorf("nested Raw() = %v, want = %v", got, want)
+	}
+	if !b.Messages[0].Equals(msg1) {
+		t.Errorf("parsed message = %v, want = %v", b.Messages[0], msg1)
+	}
+
+	data := mustMarshal(t, msg1)
+	p, err = ParsePacketRaw(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.(*Message).Raw(); !bytes.Equal(got, data) {
+		t.Errorf("Raw() = %v, want = %v", got, data)
+	}
+	if p, err = ParsePacket(string(data)); err != nil || p.(*Message).Raw() != nil {
+		t.Errorf("ParsePacket() kept raw bytes; error = %v", err)
+	}
+}
+
+func TestParseUnknownTypeTags(t *testing.T) {
+	// 'c' (char) isn't supported, so the following argument can't be read
+	data := "/a" + nulls(2) + ",icf" + nulls(4) +
+		"\x00\x00\x00\x01" + "\x00\x00\x00A" + "\x3f\x80\x00\x00"
+	p, err := ParsePacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewMessage("/a", int32(1), UnknownArg{Tag: 'c', Data: []byte("\x00\x00\x00A\x3f\x80\x00\x00")}, UnknownArg{Tag: 'f'})
+	if !p.(*Message).Equals(want) {
+		t.Errorf("ParsePacket() = %#v, want = %#v", p, want)
+	}
+	if got := mustMarshal(t, p); string(got) != data {
+		t.Errorf("MarshalBinary() = %q, want = %q", got, data)
+	}
+
+	// Within a bundle, the following elements are still read
+	bundle := NewBundle(time.Now())
+	if err := bundle.Append(want, NewMessage("/abc", "four"), NewMessage("/b", int32(2))); err != nil {
+		t.Fatal(err)
+	}
+	bdata := mustMarshal(t, bundle)
+	p, err = ParsePacket(string(bdata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.(*Bundle).CountElements(); got != 3 {
+		t.Errorf("CountElements() = %d, want = 3", got)
+	}
+	if got := mustMarshal(t, p); !bytes.Equal(got, bdata) {
+		t.Errorf("MarshalBinary() = %q, want = %q", got, bdata)
+	}
+}
+
+func TestParsePacketErrors(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		msg  string
+		err  error
+	}{
+		{"empty", "", ErrEmptyPacket},
+		{"one_byte", "/", ErrPacketTooShort},
+		{"three_bytes", "#bu", ErrPacketTooShort},
+		{"invalid", "abc" + nulls(1), ErrInvalidPacket},
+		{"invalid_element", "#bundle" + nulls(1) + nulls(8) + "\x00\x00\x00\x04abc" + nulls(1), ErrInvalidPacket},
+		{"unterminated_address", "/abcdefg", ErrShortPacket},
+		{"truncated_int", "/a" + nulls(2) + ",i" + nulls(2) + "\x00\x01", ErrShortPacket},
+		{"truncated_timetag", "/a" + nulls(2) + ",t" + nulls(2) + nulls(4), ErrShortPacket},
+		{"truncated_blob", "/a" + nulls(2) + ",b" + nulls(2) + "\x00\x00\x00\x08abcd", ErrShortPacket},
+		{"truncated_element", "#bundle" + nulls(1) + nulls(8) + "\x00\x00\x00\x10/a" + nulls(2), ErrShortPacket},
+		{"invalid_type_tags", "/a" + nulls(2) + "ii" + nulls(2), ErrInvalidPacket},
+		{"unclosed_array", "/a" + nulls(2) + ",[i" + nulls(1) + "\x00\x00\x00\x01", ErrInvalidPacket},
+		{"invalid_bundle_tag", "#bundel" + nulls(1) + nulls(8), ErrInvalidPacket},
+	} {
+		_, err := ParsePacket(tt.msg)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: ParsePacket() error = %v, want = %v", tt.desc, err, tt.err)
+		}
+		if tt.err != ErrPacketTooShort && errors.Is(err, ErrPacketTooShort) {
+			t.Errorf("%s: ParsePacket() error = %v, want not %v", tt.desc, err, ErrPacketTooShort)
+		}
+	}
+
+	// A datagram cut off by the read buffer isn't a parse error of its content
+	for _, err := range []error{ErrEmptyPacket, ErrShortPacket, ErrInvalidPacket} {
+		if errors.Is(ErrPacketTruncated, err) {
+			t.Errorf("ErrPacketTruncated wraps %v", err)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		packet Packet
+		err    error
+	}{
+		{"no_leading_slash", NewMessage("a"), ErrInvalidAddress},
+		{"unsupported_type", NewMessage("/a", struct{}{}), ErrUnsupportedType},
+		{"unsupported_array_element", NewMessage("/a", []interface{}{int32(1), uintptr(2)}), ErrUnsupportedType},
+		{"bundle_element", &Bundle{Messages: []*Message{NewMessage("/a", map[string]int{})}}, ErrUnsupportedType},
+	} {
+		if _, err := tt.packet.MarshalBinary(); !errors.Is(err, tt.err) {
+			t.Errorf("%s: MarshalBinary() error = %v, want = %v", tt.desc, err, tt.err)
+		}
+	}
+}
+
+func TestPacketPredicates(t *testing.T) {
+	msg := NewMessage("/a")
+	bundle := NewBundle(time.Now())
+	for _, tt := range []struct {
+		desc     string
+		p        Packet
+		isMsg    bool
+		isBundle bool
+	}{
+		{"message", msg, true, false},
+		{"bundle", bundle, false, true},
+		{"nil", nil, false, false},
+		{"nil_message", (*Message)(nil), false, false},
+		{"nil_bundle", (*Bundle)(nil), false, false},
+	} {
+		if got := IsMessage(tt.p); got != tt.isMsg {
+			t.Errorf("%s: IsMessage() = %t, want = %t", tt.desc, got, tt.isMsg)
+		}
+		if got := IsBundle(tt.p); got != tt.isBundle {
+			t.Errorf("%s: IsBundle() = %t, want = %t", tt.desc, got, tt.isBundle)
+		}
+		if m, ok := AsMessage(tt.p); ok != tt.isMsg || ok && m != msg {
+			t.Errorf("%s: AsMessage() = %v, %t, want ok = %t", tt.desc, m, ok, tt.isMsg)
+		}
+		if b, ok := AsBundle(tt.p); ok != tt.isBundle || ok && b != bundle {
+			t.Errorf("%s: AsBundle() = %v, %t, want ok = %t", tt.desc, b, ok, tt.isBundle)
+		}
+	}
+}
+
+func TestFlattenBundle(t *testing.T) {
+	a, b, c, d := NewMessage("/a"), NewMessage("/b"), NewMessage("/c"), NewMessage("/d")
+	outer := NewBundle(time.Unix(1000, 0))
+	inner := NewBundle(time.Unix(2000, 0))
+	if err := inner.Append(c, NewBundle(time.Unix(3000, 0))); err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.Append(a, inner, b); err != nil {
+		t.Fatal(err)
+	}
+	deepest := inner.Bundles[0]
+	if err := deepest.Append(d); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		pkt  Packet
+		want []*Message
+		tags []uint64
+	}{
+		{"message", a, []*Message{a}, []uint64{1}},
+		{"nested", outer, []*Message{a, b, c, d},
+			[]uint64{outer.Timetag.TimeTag(), outer.Timetag.TimeTag(), inner.Timetag.TimeTag(), deepest.Timetag.TimeTag()}},
+		{"empty", NewBundle(time.Now()), nil, nil},
+		{"nil", nil, nil, nil},
+		{"nil_message", (*Message)(nil), nil, nil},
+	} {
+		if got := FlattenBundle(tt.pkt); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FlattenBundle() = %v, want = %v", tt.desc, got, tt.want)
+		}
+		timed := FlattenBundleTimetags(tt.pkt)
+		if len(timed) != len(tt.want) {
+			t.Errorf("%s: FlattenBundleTimetags() returned %d messages, want = %d", tt.desc, len(timed), len(tt.want))
+			continue
+		}
+		for i, tm := range timed {
+			if tm.Message != tt.want[i] || tm.Timetag.TimeTag() != tt.tags[i] {
+				t.Errorf("%s: message %d = %v with time tag %d, want = %v with %d",
+					tt.desc, i, tm.Message, tm.Timetag.TimeTag(), tt.want[i], tt.tags[i])
+			}
+		}
+	}
+}
+
+func TestNewImmediateAndScheduledBundle(t *testing.T) {
+	msgs := []Packet{NewMessage("/a"), NewMessage("/b")}
+	immediate, err := NewImmediateBundle(msgs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !immediate.Timetag.IsImmediate() {
+		t.Errorf("Timetag = %d, want = 1", immediate.Timetag.TimeTag())
+	}
+	if got := immediate.CountElements(); got != 2 {
+		t.Errorf("CountElements() = %d, want = 2", got)
+	}
+
+	at := time.Now().Add(time.Second)
+	scheduled, err := NewScheduledBundle(at, msgs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := scheduled.Timetag.TimeTag(), NewTimetag(at).TimeTag(); got != want {
+		t.Errorf("Timetag = %d, want = %d", got, want)
+	}
+	if got := scheduled.CountElements(); got != 2 {
+		t.Errorf("CountElements() = %d, want = 2", got)
+	}
+
+	if _, err := NewImmediateBundle(NewMessage("/a"), nil); err == nil {
+		t.Error("NewImmediateBundle() expected an error for a nil packet")
+	}
+	if _, err := NewScheduledBundle(at, nil); err == nil {
+		t.Error("NewScheduledBundle() expected an error for a nil packet")
+	}
+}
+
+func TestBundle_Append(t *testing.T) {
+	bundle := NewBundle(time.Now())
+	if err := bundle.Append(NewMessage("/a"), NewBundle(time.Now()), NewMessage("/b")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := bundle.Append(NewMessage("/loop")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := bundle.CountElements(), 6; got != want {
+		t.Errorf("CountElements() = %d, want = %d", got, want)
+	}
+	if got, want := len(bundle.Messages), 5; got != want {
+		t.Errorf("len(Messages) = %d, want = %d", got, want)
+	}
+
+	var nilMsg *Message
+	for _, p := range []Packet{nil, nilMsg} {
+		if err := bundle.Append(NewMessage("/c"), p); err == nil {
+			t.Errorf("Append(%#v) expected an error", p)
+		}
+	}
+	if got, want := bundle.CountElements(), 6; got != want {
+		t.Errorf("CountElements() = %d after failed Append, want = %d", got, want)
+	}
+}
+
+func TestParseBundleTimetag(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	for _, tt := range []struct {
+		desc      string
+		timetag   *Timetag
+		immediate bool
+	}{
+		{"immediate", NewTimetagFromTimetag(1), true},
+		{"future", NewTimetag(future), false},
+	} {
+		data, err := (&Bundle{Timetag: *tt.timetag}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkt, err := ParsePacket(string(data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		got := pkt.(*Bundle).Timetag
+		if got.TimeTag() != tt.timetag.TimeTag() {
+			t.Errorf("%s: TimeTag() = %d, want = %d", tt.desc, got.TimeTag(), tt.timetag.TimeTag())
+		}
+		if got.IsImmediate() != tt.immediate {
+			t.Errorf("%s: IsImmediate() = %t, want = %t", tt.desc, got.IsImmediate(), tt.immediate)
+		}
+		if tt.immediate && got.ExpiresIn() != 0 {
+			t.Errorf("%s: ExpiresIn() = %v, want = 0", tt.desc, got.ExpiresIn())
+		}
+		if !tt.immediate && got.ExpiresIn() < 59*time.Minute {
+			t.Errorf("%s: ExpiresIn() = %v, want about 1h", tt.desc, got.ExpiresIn())
+		}
+	}
+}
+
+func TestTimetagFraction(t *testing.T) {
+	for _, tt := range []struct {
+		ns       int
+		fraction uint32
+	}{
+		{0, 0},
+		{5e8, 0x80000000},
+		{25e7, 0x40000000},
+		{1, 4},
+		{999999999, 0xfffffffc},
+	} {
+		tag := NewTimetag(time.Unix(1e9, int64(tt.ns)))
+		if got := tag.FractionalSecond(); got != tt.fraction {
+			t.Errorf("%d ns: FractionalSecond() = %#x, want = %#x", tt.ns, got, tt.fraction)
+		}
+		if got := NewTimetagFromTimetag(tag.TimeTag()).Time().Nanosecond(); got != tt.ns {
+			t.Errorf("%d ns: Time().Nanosecond() = %d", tt.ns, got)
+		}
+	}
+}
+
+func TestTimetagArithmetic(t *testing.T) {
+	// Just before the end of a second
+	base := NewTimetagFromTimetag(5<<32 | 0xffffffff)
+	next := base.Add(time.Nanosecond)
+	if got := next.SecondsSinceEpoch(); got != 6 {
+		t.Errorf("Add(1ns).SecondsSinceEpoch() = %d, want = 6", got)
+	}
+	if got := next.FractionalSecond(); got != 3 {
+		t.Errorf("Add(1ns).FractionalSecond() = %d, want = 3", got)
+	}
+	if prev := next.Add(-time.Nanosecond); prev.SecondsSinceEpoch() != 5 {
+		t.Errorf("Add(-1ns) = %08x.%08x, want = 5 seconds", prev.SecondsSinceEpoch(), prev.FractionalSecond())
+	}
+
+	for _, d := range []time.Duration{
+		0, time.Nanosecond, -time.Nanosecond, 999999999, time.Second, -time.Second,
+		1500 * time.Millisecond, -1500 * time.Millisecond, 5 * time.Millisecond, 24 * time.Hour,
+	} {
+		later := base.Add(d)
+		if got := later.Sub(*base); got != d {
+			t.Errorf("Add(%v).Sub() = %v, want = %v", d, got, d)
+		}
+		if got := later.Before(*base); got != (d < 0) {
+			t.Errorf("Add(%v).Before() = %t", d, got)
+		}
+		if got := later.After(*base); got != (d > 0) {
+			t.Errorf("Add(%v).After() = %t", d, got)
+		}
+	}
+
+	now := time.Now()
+	tag := NewTimetag(now)
+	later := tag.Add(5 * time.Millisecond)
+	if got, want := later.Time(), now.Add(5*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Add(5ms).Time() = %v, want = %v", got, want)
+	}
+	if immediate := NewTimetagFromTimetag(1); !immediate.Before(*tag) {
+		t.Error("immediate time tag isn't before other time tags")
+	}
+}
+
+func TestDispatchBundleTimetag(t *testing.T) {
+	d := NewStandardDispatcher()
+	called := make(chan bool, 1)
+	if err := d.AddMsgHandler("/a", func(msg *Message) { called <- true }); err != nil {
+		t.Fatal(err)
+	}
+
+	// Immediate bundles are dispatched before Dispatch returns.
+	immediate := &Bundle{Timetag: *NewTimetagFromTimetag(1)}
+	immediate.Append(NewMessage("/a"))
+	d.Dispatch(immediate)
+	select {
+	case <-called:
+	default:
+		t.Error("immediate bundle wasn't dispatched right away")
+	}
+
+	// Future bundles are dispatched at their time.
+	future := NewBundle(time.Now().Add(100 * time.Millisecond))
+	future.Append(NewMessage("/a"))
+	d.Dispatch(future)
+	select {
+	case <-called:
+		t.Error("future bundle was dispatched too early")
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Error("future bundle wasn't dispatched")
+	}
+}
+
+func TestClientSendBundle(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var got []string
+	d := NewStandardDispatcher()
+	for _, addr := range []string{"/a", "/b", "/c"} {
+		if err := d.AddMsgHandler(addr, func(msg *Message) {
+			got = append(got, msg.Address)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := &Server{Dispatcher: d}
+
+	tt := time.Now().Add(-time.Second)
+	nested := NewBundle(tt)
+	if err := nested.Append(NewMessage("/c", "nested")); err != nil {
+		t.Fatal(err)
+	}
+	bundle := NewBundle(tt)
+	if err := bundle.Append(NewMessage("/a", int32(1)), NewMessage("/b", int32(2)), nested); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	if err := client.Send(bundle); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.ServeOne(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if want := []string{"/a", "/b", "/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched = %v, want = %v", got, want)
+	}
+
+	// The received bundle must be equal to the sent one, including the timetag
+	if err := client.Send(bundle); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	p, err := server.ReceivePacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustMarshal(t, p), mustMarshal(t, bundle); !bytes.Equal(got, want) {
+		t.Errorf("received bundle = %v, want = %v", got, want)
+	}
+	if got, want := p.(*Bundle).Timetag.TimeTag(), bundle.Timetag.TimeTag(); got != want {
+		t.Errorf("Timetag = %d, want = %d", got, want)
+	}
+}
+
+func TestServerDispatchPacket(t *testing.T) {
+	// Every handler records its own address
+	var calls []string
+	server := &Server{}
+	for _, addr := range []string{"/a", "/b", "/b/c"} {
+		addr := addr
+		if err := server.HandleFunc(addr, func(*Message) { calls = append(calls, addr) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	later := NewBundle(time.Now().Add(time.Hour))
+	if err := later.Append(NewMessage("/b/c")); err != nil {
+		t.Fatal(err)
+	}
+	bundle := NewBundle(time.Time{})
+	if err := bundle.Append(later, NewMessage("/a"), NewMessage("/?")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		packet Packet
+		want   int
+		calls  []string
+	}{
+		{"message", NewMessage("/a"), 1, []string{"/a"}},
+		{"pattern", NewMessage("/?"), 2, []string{"/a", "/b"}},
+		{"unhandled", NewMessage("/x"), 0, nil},
+		{"bundle", bundle, 4, []string{"/a", "/a", "/b", "/b/c"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			if got := server.DispatchPacket(tt.packet); got != tt.want {
+				t.Errorf("DispatchPacket() = %d, want = %d", got, tt.want)
+			}
+			sort.Strings(calls)
+			if !reflect.DeepEqual(calls, tt.calls) {
+				t.Errorf("handlers called for %v, want = %v", calls, tt.calls)
+			}
+		})
+	}
+
+	server.DisallowBundles = true
+	if got := server.DispatchPacket(bundle); got != 0 {
+		t.Errorf("DispatchPacket() = %d with DisallowBundles, want = 0", got)
+	}
+
+	// The number of handlers is unknown for other dispatchers
+	var dispatched bool
+	server = &Server{Dispatcher: dispatcherFunc(func(Packet) { dispatched = true })}
+	if got := server.DispatchPacket(NewMessage("/a")); got != -1 || !dispatched {
+		t.Errorf("DispatchPacket() = %d, dispatched = %t, want = -1, true", got, dispatched)
+	}
+}
+
+func TestServerAtomicBundles(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(msg *Message) {
+		mu.Lock()
+		order = append(order, msg.Address)
+		mu.Unlock()
+	}
+
+	entered := make(chan bool)
+	release := make(chan bool)
+	done := make(chan bool, 3)
+	d := NewStandardDispatcher()
+	handlers := map[string]HandlerFunc{
+		"/a": func(msg *Message) {
+			entered <- true
+			<-release
+			record(msg)
+		},
+		"/b": record,
+		"/x": record,
+	}
+	for addr, h := range handlers {
+		h := h
+		if err := d.AddMsgHandler(addr, func(msg *Message) {
+			h(msg)
+			done <- true
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := &Server{Dispatcher: d, AtomicBundles: true}
+
+	bundle := NewBundle(time.Now().Add(-time.Second))
+	if err := bundle.Append(NewMessage("/a"), NewMessage("/b")); err != nil {
+		t.Fatal(err)
+	}
+	go server.dispatch(bundle, nil)
+	<-entered
+	go server.dispatch(NewMessage("/x"), nil)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for handlers")
+		}
+	}
+
+	if want := []string{"/a", "/b", "/x"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("dispatch order = %v, want = %v", order, want)
+	}
+
+	// Bundles in the future are dispatched once their time tag is reached
+	order = nil
+	bundle = NewBundle(time.Now().Add(50 * time.Millisecond))
+	if err := bundle.Append(NewMessage("/b")); err != nil {
+		t.Fatal(err)
+	}
+	server.dispatch(bundle, nil)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("future bundle wasn't dispatched")
+	}
+}
+
 func TestOscMessageMatch(t *testing.T) {
 	tc := []struct {
 		desc        string
@@ -602,6 +3082,36 @@ func TestOscMessageMatch(t *testing.T) {
 			"/a/bob",
 			false,
 		},
+		{
+			"match a direct child",
+			"/a/*",
+			"/a/b",
+			true,
+		},
+		{
+			"don't match '*' across '/'",
+			"/a/*",
+			"/a/b/c",
+			false,
+		},
+		{
+			"match a grandchild",
+			"/a/*/*",
+			"/a/b/c",
+			true,
+		},
+		{
+			"don't match fewer parts",
+			"/a/*/*",
+			"/a/b",
+			false,
+		},
+		{
+			"don't match within an address",
+			"/a/b",
+			"/x/a/b",
+			false,
+		},
 	}
 
 	for _, tt := range tc {
@@ -614,6 +3124,36 @@ func TestOscMessageMatch(t *testing.T) {
 	}
 }
 
+func TestMessage_MatchPattern(t *testing.T) {
+	for _, tt := range []struct {
+		addr    string
+		pattern string
+		want    bool
+		ok      bool
+	}{
+		{"/a/b", "/a/b", true, true},
+		{"/a/b", "/a/*", true, true},
+		{"/a/b/c", "/a/*", false, true},
+		{"/a/b/c", "/a/*/*", true, true},
+		{"/a/b", "/a/*/*", false, true},
+		{"/a/b", "/*", false, true},
+		{"/a/foo", "/a/{foo,bar}", true, true},
+		{"/a/bob", "/a/{foo,bar}", false, true},
+		{"/a/x1", "/?/[wx][0-9]", true, true},
+		{"/a/b", "/a", false, true},
+		{"/a/b", "/a/[b", false, false},
+		{"/a/b", "a/b", false, false},
+	} {
+		got, err := NewMessage(tt.addr).MatchPattern(tt.pattern)
+		if (err == nil) != tt.ok {
+			t.Errorf("MatchPattern(%q) error = %v, want ok = %t", tt.pattern, err, tt.ok)
+		}
+		if got != tt.want {
+			t.Errorf("%q: MatchPattern(%q) = %t, want = %t", tt.addr, tt.pattern, got, tt.want)
+		}
+	}
+}
+
 const zero = string(byte(0))
 
 // nulls returns a string of `i` nulls.
@@ -625,6 +3165,19 @@ func nulls(i int) string {
 	return s
 }
 
+// freeUDPPort returns a UDP port on 127.0.0.1 that was free when it was
+// looked up, for tests that need to know the source port of a client
+// before sending.
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
 // makePacket creates a fake Message Packet.
 func makePacket(addr string, args []string) Packet {
 	msg := NewMessage(addr)
@@ -633,3 +3186,113 @@ func makePacket(addr string, args []string) Packet {
 	}
 	return msg
 }
+
+func TestMarshalBigEndian(t *testing.T) {
+	for _, tt := range []struct {
+		arg  interface{}
+		want string
+	}{
+		{int32(1), "\x00\x00\x00\x01"},
+		{int32(-2), "\xff\xff\xff\xfe"},
+		{float32(1), "\x3f\x80\x00\x00"},
+		{int64(0x0102030405060708), "\x01\x02\x03\x04\x05\x06\x07\x08"},
+		{float64(1), "\x3f\xf0\x00\x00\x00\x00\x00\x00"},
+		{*NewTimetagFromTimetag(0x0102030405060708), "\x01\x02\x03\x04\x05\x06\x07\x08"},
+		{[]byte{0xaa}, "\x00\x00\x00\x01\xaa\x00\x00\x00"},
+	} {
+		data := mustMarshal(t, NewMessage("/a", tt.arg))
+		// Skip the address and the type tag string
+		if got := string(data[8:]); got != tt.want {
+			t.Errorf("%T(%v): encoded as %q, want = %q", tt.arg, tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	args := []interface{}{
+		int32(0), int32(1), int32(-1), int32(math.MaxInt32), int32(math.MinInt32),
+		float32(0), float32(-1.5), float32(math.MaxFloat32), float32(math.SmallestNonzeroFloat32),
+		math.Float32frombits(0x007fffff), // Largest subnormal float32
+		float32(math.Inf(1)), float32(math.Inf(-1)),
+		"", "a", "abc", "abcd", "abcde", "\xc0\xdb",
+		[]byte{}, []byte{0}, []byte{1, 2, 3}, []byte{1, 2, 3, 4}, []byte{0xff, 0, 0xff, 0, 0xff},
+		bytes.Repeat([]byte{1, 2, 3}, 5000),
+		int64(0), int64(math.MaxInt64), int64(math.MinInt64),
+		float64(0), float64(-2.25), math.MaxFloat64, math.SmallestNonzeroFloat64,
+		math.Float64frombits(0x000fffffffffffff), // Largest subnormal float64
+		math.Inf(1), math.Inf(-1),
+		*NewTimetagFromTimetag(1), *NewTimetagFromTimetag(0x0102030405060708), *NewTimetagFromTimetag(math.MaxUint64),
+		true, false, nil,
+		[]interface{}{}, []interface{}{int32(1), "s", []interface{}{float64(2), []byte{3}}},
+	}
+
+	var messages []*Message
+	for _, arg := range args {
+		messages = append(messages, NewMessage("/single", arg))
+	}
+	// Mixed messages, with every argument following every other argument
+	messages = append(messages, NewMessage("/mixed", args...))
+	reversed := make([]interface{}, len(args))
+	for i, arg := range args {
+		reversed[len(args)-1-i] = arg
+	}
+	messages = append(messages, NewMessage("/mixed/reversed", reversed...))
+	messages = append(messages, NewMessage("/nan", float32(math.NaN()), math.NaN()))
+
+	for _, msg := range messages {
+		data := mustMarshal(t, msg)
+		if len(data)%4 != 0 {
+			t.Errorf("%v: size %d isn't a multiple of 4", msg, len(data))
+		}
+		p, err := ParsePacket(string(data))
+		if err != nil {
+			t.Errorf("%v: ParsePacket() error = %v", msg, err)
+			continue
+		}
+		if got := mustMarshal(t, p); !bytes.Equal(got, data) {
+			t.Errorf("%v: encoded again as %q, want = %q", msg, got, data)
+		}
+		// NaN isn't equal to itself
+		if msg.Address != "/nan" && !p.(*Message).Equals(msg) {
+			t.Errorf("ParsePacket() = %#v, want = %#v", p, msg)
+		}
+	}
+}
+
+func FuzzParsePacket(f *testing.F) {
+	bundle := NewBundle(time.Unix(0, 0))
+	if err := bundle.Append(NewMessage("/b", int32(1), []interface{}{"x"})); err != nil {
+		f.Fatal(err)
+	}
+	for _, p := range []Packet{
+		NewMessage("/a"),
+		NewMessage("/a", int32(1), float32(2), "three", []byte{4}, int64(5), float64(6), true, nil),
+		bundle,
+	} {
+		data, err := p.MarshalBinary()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	// Every packet that can be parsed must be encoded and parsed again to
+	// the same bytes
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := ParsePacket(string(data))
+		if err != nil {
+			return
+		}
+		encoded, err := p.MarshalBinary()
+		if err != nil {
+			return
+		}
+		p2, err := ParsePacket(string(encoded))
+		if err != nil {
+			t.Fatalf("ParsePacket(%q) error = %v", encoded, err)
+		}
+		if again := mustMarshal(t, p2); !bytes.Equal(again, encoded) {
+			t.Errorf("encoded %q again as %q", encoded, again)
+		}
+	})
+}
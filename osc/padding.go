@@ -0,0 +1,50 @@
+package osc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// PadBytesNeeded returns the number of padding bytes needed to extend an
+// element of length n to a multiple of 4 bytes. For OSC-strings, n must
+// include the null terminator.
+func PadBytesNeeded(n int) int {
+	return padBytesNeeded(n)
+}
+
+// WritePaddedString writes str as an OSC-string to w, i.e. followed by a null
+// terminator and padding bytes. str is truncated at its first null byte. It
+// returns the number of bytes written.
+func WritePaddedString(w io.Writer, str string) (int, error) {
+	var buf bytes.Buffer
+	if _, err := writePaddedString(str, &buf); err != nil {
+		return 0, err
+	}
+	return w.Write(buf.Bytes())
+}
+
+// ReadPaddedString reads an OSC-string from r and removes its padding bytes
+// from r. It returns the string without the null terminator and the number of
+// bytes read.
+func ReadPaddedString(r *bufio.Reader) (string, int, error) {
+	return readPaddedString(r)
+}
+
+// WriteBlob writes data as an OSC-blob to w, i.e. prefixed with its size as
+// int32 and followed by padding bytes. It returns the number of bytes
+// written.
+func WriteBlob(w io.Writer, data []byte) (int, error) {
+	var buf bytes.Buffer
+	if _, err := writeBlob(data, &buf); err != nil {
+		return 0, err
+	}
+	return w.Write(buf.Bytes())
+}
+
+// ReadBlob reads an OSC-blob from r and removes its padding bytes from r. It
+// returns the blob data and the number of bytes read. The whole blob must be
+// buffered by r.
+func ReadBlob(r *bufio.Reader) ([]byte, int, error) {
+	return readBlob(r)
+}
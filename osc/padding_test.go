@@ -0,0 +1,67 @@
+package osc
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestPaddedStringRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		str  string
+		size int
+	}{
+		{"", 4},
+		{"abc", 4},
+		{"abcd", 8},
+		{"/address", 12},
+	} {
+		var buf bytes.Buffer
+		n, err := WritePaddedString(&buf, tt.str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.size || buf.Len() != tt.size {
+			t.Errorf("WritePaddedString(%q) wrote %d (%d) bytes, want = %d", tt.str, n, buf.Len(), tt.size)
+		}
+		if got, want := PadBytesNeeded(len(tt.str)+1), tt.size-len(tt.str)-1; got != want {
+			t.Errorf("PadBytesNeeded(%d) = %d, want = %d", len(tt.str)+1, got, want)
+		}
+
+		got, n, err := ReadPaddedString(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.str || n != tt.size {
+			t.Errorf("ReadPaddedString() = %q, %d, want = %q, %d", got, n, tt.str, tt.size)
+		}
+	}
+}
+
+func TestBlobRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		data []byte
+		size int
+	}{
+		{[]byte{1}, 8},
+		{[]byte{1, 2, 3, 4}, 8},
+		{[]byte{1, 2, 3, 4, 5}, 12},
+	} {
+		var buf bytes.Buffer
+		n, err := WriteBlob(&buf, tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.size {
+			t.Errorf("WriteBlob(%v) wrote %d bytes, want = %d", tt.data, n, tt.size)
+		}
+
+		got, n, err := ReadBlob(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tt.data) || n != tt.size {
+			t.Errorf("ReadBlob() = %v, %d, want = %v, %d", got, n, tt.data, tt.size)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package osc
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Pipe returns a Client and a connection that are connected in memory. The
+// packets sent by the client can be received from the connection, e.g. with
+// Server.Serve, ServeOne or ReceivePacket, without using the network. Unlike
+// UDP, packets are never lost: Send blocks until the connection has room for
+// the packet. Closing the connection makes sending fail.
+func Pipe() (*Client, net.PacketConn) {
+	p := &pipeConn{
+		packets: make(chan []byte, pipeCapacity),
+		closed:  make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+	return &Client{pipe: p}, p
+}
+
+// pipeCapacity is the number of packets a pipe buffers before Send blocks.
+const pipeCapacity = 64
+
+// pipeAddr is the address of both ends of a pipe.
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// pipeConn is the receiving end of a pipe.
+type pipeConn struct {
+	packets chan []byte
+	closed  chan struct{}
+
+	mu       sync.Mutex
+	once     sync.Once
+	deadline time.Time
+	changed  chan struct{} // Closed when the deadline changes
+}
+
+// ReadFrom implements the net.PacketConn interface.
+func (p *pipeConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		if p.isClosed() {
+			return 0, nil, net.ErrClosed
+		}
+		p.mu.Lock()
+		deadline, changed := p.deadline, p.changed
+		p.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			expired = timer.C
+		}
+
+		var n int
+		var addr net.Addr
+		var err error
+		retry := false
+		select {
+		case data := <-p.packets:
+			n, addr = copy(b, data), pipeAddr{}
+		case <-p.closed:
+			err = net.ErrClosed
+		case <-expired:
+			err = os.ErrDeadlineExceeded
+		case <-changed:
+			retry = true
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if !retry {
+			return n, addr, err
+		}
+	}
+}
+
+// WriteTo implements the net.PacketConn interface. Pipes only transport
+// packets from the client to the connection, so it always fails.
+func (p *pipeConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return 0, errors.New("pipe: writing to the client isn't supported")
+}
+
+// Close implements the net.PacketConn interface.
+func (p *pipeConn) Close() error {
+	err := net.ErrClosed
+	p.once.Do(func() {
+		close(p.closed)
+		err = nil
+	})
+	return err
+}
+
+func (p *pipeConn) isClosed() bool {
+	select {
+	case <-p.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// LocalAddr implements the net.PacketConn interface.
+func (p *pipeConn) LocalAddr() net.Addr { return pipeAddr{} }
+
+// SetDeadline implements the net.PacketConn interface.
+func (p *pipeConn) SetDeadline(t time.Time) error {
+	return p.SetReadDeadline(t)
+}
+
+// SetReadDeadline implements the net.PacketConn interface.
+func (p *pipeConn) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deadline = t
+	close(p.changed)
+	p.changed = make(chan struct{})
+	return nil
+}
+
+// SetWriteDeadline implements the net.PacketConn interface. Writes to a pipe
+// connection always fail, so the deadline is ignored.
+func (p *pipeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// pipeWriter is the connection a client created by Pipe sends packets on.
+type pipeWriter struct {
+	p *pipeConn
+}
+
+func (w *pipeWriter) Write(b []byte) (int, error) {
+	if w.p.isClosed() {
+		return 0, net.ErrClosed
+	}
+	data := append([]byte{}, b...)
+	select {
+	case w.p.packets <- data:
+		return len(b), nil
+	case <-w.p.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+func (w *pipeWriter) Read(b []byte) (int, error) {
+	return 0, errors.New("pipe: reading from the client isn't supported")
+}
+
+// Close doesn't close the pipe, since the client opens a new connection for
+// every packet it sends.
+func (w *pipeWriter) Close() error                       { return nil }
+func (w *pipeWriter) LocalAddr() net.Addr                { return pipeAddr{} }
+func (w *pipeWriter) RemoteAddr() net.Addr               { return pipeAddr{} }
+func (w *pipeWriter) SetDeadline(t time.Time) error      { return nil }
+func (w *pipeWriter) SetReadDeadline(t time.Time) error  { return nil }
+func (w *pipeWriter) SetWriteDeadline(t time.Time) error { return nil }
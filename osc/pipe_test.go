@@ -0,0 +1,63 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPipe(t *testing.T) {
+	client, conn := Pipe()
+
+	var got []*Message
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/pipe", func(msg *Message) {
+		got = append(got, msg)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
+
+	for i := int32(0); i < 3; i++ {
+		if err := client.Send(NewMessage("/pipe", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := server.ServeOne(context.Background(), conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("dispatched %d messages, want = 3", len(got))
+	}
+	for i, msg := range got {
+		if want := NewMessage("/pipe", int32(i)); !msg.Equals(want) {
+			t.Errorf("message %d = %v, want = %v", i, msg, want)
+		}
+	}
+
+	// Reads time out like on a network connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := server.ServeOne(ctx, conn); err != context.DeadlineExceeded {
+		t.Errorf("ServeOne() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+	server.ReadTimeout = 10 * time.Millisecond
+	if _, err := server.ReceivePacket(conn); err == nil {
+		t.Error("expected timeout error")
+	} else if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("ReceivePacket() error = %v, want timeout", err)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(NewMessage("/pipe")); err == nil {
+		t.Error("expected error sending to closed pipe")
+	}
+	if _, err := server.ReceivePacket(conn); err == nil {
+		t.Error("expected error receiving from closed pipe")
+	}
+}
@@ -0,0 +1,63 @@
+package osc
+
+import (
+	"net"
+	"strings"
+)
+
+// QueryReplyAddress is the address of the replies the Server sends to address
+// space queries if AnswerQueries is set.
+const QueryReplyAddress = "/reply"
+
+// addressLister is implemented by dispatchers that can enumerate the
+// addresses of their handlers, like the StandardDispatcher.
+type addressLister interface {
+	Addresses() []string
+}
+
+// packetWriter is the part of net.PacketConn used to reply to packets.
+type packetWriter interface {
+	WriteTo(b []byte, addr net.Addr) (int, error)
+}
+
+// queryNode returns the node queried by the message with the given address,
+// e.g. "/synth/" for "/synth/?" and "/" for "/?", or false if the address
+// isn't a query.
+func queryNode(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, "/") || !strings.HasSuffix(addr, "/?") {
+		return "", false
+	}
+	return strings.TrimSuffix(addr, "?"), true
+}
+
+// answerQuery replies to packet if it is an address space query, see
+// Server.AnswerQueries, and reports whether it did.
+func (s *Server) answerQuery(w packetWriter, packet Packet, addr net.Addr) bool {
+	msg, ok := packet.(*Message)
+	if !ok {
+		return false
+	}
+	node, ok := queryNode(msg.Address)
+	if !ok {
+		return false
+	}
+	lister, ok := s.Dispatcher.(addressLister)
+	if !ok {
+		return false
+	}
+
+	reply := NewMessage(QueryReplyAddress, msg.Address)
+	for _, a := range lister.Addresses() {
+		if strings.HasPrefix(a, node) || a+"/" == node {
+			reply.Append(a)
+		}
+	}
+	data, err := reply.MarshalBinary()
+	if err == nil {
+		_, err = w.WriteTo(data, addr)
+	}
+	if err != nil {
+		s.logf("osc: replying to query %s from %v: %v", msg.Address, addr, err)
+	}
+	return true
+}
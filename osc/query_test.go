@@ -0,0 +1,159 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServerAnswerQueries(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	dispatched := make(chan string, 1)
+	server := &Server{AnswerQueries: true}
+	for _, addr := range []string{"/synth", "/synth/freq", "/synth/gain", "/synthesizer", "/fx/reverb"} {
+		if err := server.HandleFunc(addr, func(msg *Message) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := server.HandleFunc("*", func(msg *Message) {
+		dispatched <- msg.Address
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.ServeContext(ctx, conn)
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, tt := range []struct {
+		query string
+		want  []interface{}
+	}{
+		{"/?", []interface{}{"/?", "/fx/reverb", "/synth", "/synth/freq", "/synth/gain", "/synthesizer"}},
+		{"/synth/?", []interface{}{"/synth/?", "/synth", "/synth/freq", "/synth/gain"}},
+		{"/none/?", []interface{}{"/none/?"}},
+	} {
+		if _, err := client.WriteTo(mustMarshal(t, NewMessage(tt.query)), conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 1024)
+		n, _, err := client.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		p, err := ParsePacket(string(buf[:n]))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		reply := p.(*Message)
+		if reply.Address != QueryReplyAddress || !reflect.DeepEqual(reply.Arguments, tt.want) {
+			t.Errorf("%s: reply = %v, want = %s %v", tt.query, reply, QueryReplyAddress, tt.want)
+		}
+	}
+
+	// Queries aren't dispatched, other messages are.
+	if _, err := client.WriteTo(mustMarshal(t, NewMessage("/synth/freq")), conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case addr := <-dispatched:
+		if addr != "/synth/freq" {
+			t.Errorf("dispatched %s, want = /synth/freq", addr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestServerAnswerQueriesShadowsWildcard(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var dispatched []string
+	server := &Server{}
+	if err := server.HandleFunc("/mixer/1", func(msg *Message) {
+		dispatched = append(dispatched, msg.Address)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pattern "/mixer/?" matches "/mixer/1", unless it is answered as a
+	// query
+	for _, tt := range []struct {
+		answerQueries bool
+		dispatched    bool
+	}{
+		{false, true},
+		{true, false},
+	} {
+		server.AnswerQueries = tt.answerQueries
+		dispatched = nil
+		if _, err := client.WriteTo(mustMarshal(t, NewMessage("/mixer/?")), conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := server.ServeOne(ctx, conn)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dispatched != nil; got != tt.dispatched {
+			t.Errorf("AnswerQueries = %t: dispatched = %t, want = %t", tt.answerQueries, got, tt.dispatched)
+		}
+	}
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePacket(string(buf[:n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewMessage(QueryReplyAddress, "/mixer/?", "/mixer/1")
+	if reply := p.(*Message); !reply.Equals(want) {
+		t.Errorf("reply = %v, want = %v", reply, want)
+	}
+}
+
+func TestQueryNode(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		node string
+		ok   bool
+	}{
+		{"/?", "/", true},
+		{"/synth/?", "/synth/", true},
+		{"/synth?", "", false},
+		{"/synth", "", false},
+		{"?", "", false},
+	} {
+		node, ok := queryNode(tt.addr)
+		if node != tt.node || ok != tt.ok {
+			t.Errorf("queryNode(%q) = %q, %t, want = %q, %t", tt.addr, node, ok, tt.node, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package osc
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets is the number of token buckets above which the buckets of
+// sources that haven't sent packets recently are removed.
+const maxIdleBuckets = 1024
+
+// rateLimiter limits the rate of packets per source with token buckets.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // Tokens added per second
+	burst   float64 // Maximum number of tokens
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// tokenBucket holds the tokens of a single source.
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	dropped  uint64 // Number of dropped packets
+	flooding bool   // Whether the last packet was dropped
+}
+
+// newRateLimiter returns a rate limiter that allows rate packets per second
+// and bursts of up to burst packets per source.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the bucket of the given source. If there is no
+// token left, it returns false together with the number of packets dropped
+// from the source so far and whether this is the first of a series of dropped
+// packets.
+func (l *rateLimiter) allow(source string) (ok bool, dropped uint64, first bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, exists := l.buckets[source]
+	if !exists {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[source] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		b.dropped++
+		first = !b.flooding
+		b.flooding = true
+		return false, b.dropped, first
+	}
+	b.tokens--
+	b.flooding = false
+	return true, b.dropped, false
+}
+
+// prune removes the buckets that have been refilled completely, which are
+// the same as new buckets.
+func (l *rateLimiter) prune(now time.Time) {
+	for source, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, source)
+		}
+	}
+}
+
+// sourceKey returns the key of the rate limiter bucket for addr. Packets are
+// limited per host, since clients often send from changing ports.
+func sourceKey(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	if udp, ok := addr.(*net.UDPAddr); ok {
+		return udp.IP.String()
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// acceptFrom returns false if a packet from addr must be dropped because the
+// source exceeds the server's rate limit.
+func (s *Server) acceptFrom(addr net.Addr) bool {
+	if s.RateLimit <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	if s.limiter == nil {
+		s.limiter = newRateLimiter(s.RateLimit, s.RateBurst)
+	}
+	limiter := s.limiter
+	s.mu.Unlock()
+
+	ok, dropped, first := limiter.allow(sourceKey(addr))
+	if ok {
+		return true
+	}
+	s.stats.packetsDropped.Add(1)
+	if first {
+		s.logf("osc: rate limit exceeded by %v, dropping packets", addr)
+	}
+	if s.OnDrop != nil {
+		s.OnDrop(addr, dropped)
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(10, 2)
+	l.now = func() time.Time { return now }
+
+	allow := func(source string) bool {
+		ok, _, _ := l.allow(source)
+		return ok
+	}
+
+	// A burst of two packets is allowed, the third one is dropped.
+	for i, want := range []bool{true, true, false} {
+		if got := allow("a"); got != want {
+			t.Errorf("packet %d: allow() = %t, want = %t", i, got, want)
+		}
+	}
+
+	// Other sources aren't affected.
+	if !allow("b") {
+		t.Error("Expected packet from another source to be allowed")
+	}
+
+	// After 100ms one token has been added.
+	now = now.Add(100 * time.Millisecond)
+	if !allow("a") {
+		t.Error("Expected packet to be allowed after refill")
+	}
+	ok, dropped, first := l.allow("a")
+	if ok || dropped != 2 || !first {
+		t.Errorf("allow() = %t, %d, %t, want = false, 2, true", ok, dropped, first)
+	}
+	ok, dropped, first = l.allow("a")
+	if ok || dropped != 3 || first {
+		t.Errorf("allow() = %t, %d, %t, want = false, 3, false", ok, dropped, first)
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(1, 1)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < maxIdleBuckets; i++ {
+		l.allow(string(rune(i)))
+	}
+	now = now.Add(time.Second)
+	l.allow("new")
+	if got := len(l.buckets); got != 1 {
+		t.Errorf("Expected refilled buckets to be pruned, %d buckets left", got)
+	}
+}
+
+func TestSourceKey(t *testing.T) {
+	for _, tt := range []struct {
+		addr net.Addr
+		want string
+	}{
+		{nil, ""},
+		{&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}, "10.0.0.1"},
+		{&net.TCPAddr{IP: net.IPv6loopback, Port: 1234}, "::1"},
+	} {
+		if got := sourceKey(tt.addr); got != tt.want {
+			t.Errorf("sourceKey(%v) = %q, want = %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var dropped uint64
+	d := NewStandardDispatcher()
+	handled := 0
+	if err := d.AddMsgHandler("/flood", func(msg *Message) { handled++ }); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		Dispatcher: d,
+		RateLimit:  0.001,
+		RateBurst:  2,
+		OnDrop:     func(addr net.Addr, n uint64) { dropped = n },
+	}
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	for i := 0; i < 5; i++ {
+		if err := client.Send(NewMessage("/flood")); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.ServeOne(context.Background(), conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if handled != 2 {
+		t.Errorf("handled %d packets, want 2", handled)
+	}
+	if dropped != 3 {
+		t.Errorf("dropped %d packets, want 3", dropped)
+	}
+}
@@ -0,0 +1,117 @@
+package osc
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// Recorder records OSC packets to a stream, together with the time at which
+// they were recorded relative to the creation of the Recorder. Recordings can
+// be replayed with a Player. Every record consists of the time offset in
+// nanoseconds as int64, followed by the packet as written by WritePacket.
+//
+// A Recorder implements the Dispatcher interface, so it can be used as the
+// Dispatcher of a Server to record all received packets.
+type Recorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+}
+
+// Verify that Recorder implements the Dispatcher interface.
+var _ Dispatcher = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder that writes to w. The time offsets of the
+// records are measured from now, using the monotonic clock.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w, start: time.Now()}
+}
+
+// Record writes the packet with the current time offset to the recording.
+func (r *Recorder) Record(p Packet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	offset := make([]byte, 8)
+	binary.BigEndian.PutUint64(offset, uint64(time.Since(r.start)))
+	if _, err := r.w.Write(offset); err != nil {
+		return err
+	}
+	_, err := WritePacket(r.w, p)
+	return err
+}
+
+// Dispatch records the packet. Since Dispatch can't return an error, the
+// first error is kept and returned by Err. Implements the Dispatcher
+// interface.
+func (r *Recorder) Dispatch(p Packet) {
+	if err := r.Record(p); err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Err returns the first error that occurred in Dispatch.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Player replays a recording made by a Recorder through a Client.
+type Player struct {
+	r io.Reader
+	c *Client
+}
+
+// NewPlayer returns a Player that reads the recording from r and sends the
+// packets with c.
+func NewPlayer(r io.Reader, c *Client) *Player {
+	return &Player{r: r, c: c}
+}
+
+// Play sends all recorded packets, preserving the time offsets between them.
+// It returns nil when the end of the recording is reached, or the context's
+// error if the context is done before.
+func (p *Player) Play(ctx context.Context) error {
+	start := time.Now()
+	for {
+		var offset int64
+		if err := binary.Read(p.r, binary.BigEndian, &offset); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		packet, err := ReadPacket(p.r)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		if wait := time.Duration(offset) - time.Since(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := p.c.Send(packet); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package osc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecorderPlayer(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	recorder.Dispatch(NewMessage("/first", int32(1)))
+	time.Sleep(100 * time.Millisecond)
+	if err := recorder.Record(NewMessage("/second", "two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	played := make(chan error)
+	go func() {
+		played <- NewPlayer(&buf, client).Play(context.Background())
+	}()
+
+	server := &Server{ReadTimeout: time.Second}
+	var received []time.Time
+	for _, want := range []string{"/first", "/second"} {
+		p, err := server.ReceivePacket(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, time.Now())
+		if got := p.(*Message).Address; got != want {
+			t.Errorf("wrong address; got = %s, want = %s", got, want)
+		}
+	}
+	if err := <-played; err != nil {
+		t.Fatal(err)
+	}
+	if d := received[1].Sub(received[0]); d < 90*time.Millisecond {
+		t.Errorf("packets were played %v apart, want about 100ms", d)
+	}
+}
+
+func TestPlayerContext(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	recorder.start = recorder.start.Add(-time.Hour)
+	if err := recorder.Record(NewMessage("/late")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := NewPlayer(&buf, NewClient("127.0.0.1", 9)).Play(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Play() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPlayerTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewRecorder(&buf).Record(NewMessage("/a")); err != nil {
+		t.Fatal(err)
+	}
+	buf.Truncate(10)
+
+	err := NewPlayer(&buf, NewClient("127.0.0.1", 9)).Play(context.Background())
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Play() error = %v, want = %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,87 @@
+package osc
+
+import (
+	"errors"
+	"net"
+)
+
+// Reply returns a new message to the given address with the arguments args
+// as a reply to msg. It is a shorthand for NewMessage, msg isn't used. Use
+// ReplyEcho to echo a correlation argument of msg. Send the reply back with
+// Server.Respond.
+func (msg *Message) Reply(addr string, args ...interface{}) *Message {
+	return NewMessage(addr, args...)
+}
+
+// ReplyEcho is like Reply, but echoes the first argument of msg, e.g. a
+// request ID, as the first argument of the reply, followed by args, so that
+// the sender can correlate the reply with its request. The argument is
+// echoed as is, whatever its type. If msg has no arguments, the reply only
+// has args.
+func (msg *Message) ReplyEcho(addr string, args ...interface{}) *Message {
+	reply := NewMessage(addr)
+	if len(msg.Arguments) > 0 {
+		reply.Append(msg.Arguments[0])
+	}
+	reply.Append(args...)
+	return reply
+}
+
+// Respond sends the packet reply to addr, the source address of a received
+// packet, e.g. as passed to an AddrHandler. The reply is sent from the
+// connection the server is serving on, so that it comes from the address the
+// originator sent its packet to. If the server is serving on several
+// connections, the first one of the same network and IP version as addr is
+// used. An error is returned if the server isn't serving on a suitable
+// connection, e.g. when driven by ServeOne only.
+func (s *Server) Respond(addr net.Addr, reply Packet) error {
+	if addr == nil {
+		return errors.New("no address to respond to")
+	}
+	data, err := reply.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	c := s.replyConn(addr)
+	if c == nil {
+		return errors.New("server isn't serving on a connection to respond from")
+	}
+	_, err = c.WriteTo(data, addr)
+	return err
+}
+
+// replyConn returns the connection to respond to addr from, or nil.
+func (s *Server) replyConn(addr net.Addr) net.PacketConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fallback net.PacketConn
+	for _, c := range s.conns {
+		local := c.LocalAddr()
+		if local.Network() != addr.Network() {
+			continue
+		}
+		if fallback == nil {
+			fallback = c
+		}
+		if sameIPVersion(local, addr) {
+			return c
+		}
+	}
+	return fallback
+}
+
+// sameIPVersion reports whether a and b are UDP addresses of the same IP
+// version. Addresses of other types are considered to match.
+func sameIPVersion(a, b net.Addr) bool {
+	ua, ok := a.(*net.UDPAddr)
+	if !ok {
+		return true
+	}
+	ub, ok := b.(*net.UDPAddr)
+	if !ok {
+		return true
+	}
+	return (ua.IP.To4() != nil) == (ub.IP.To4() != nil)
+}
@@ -0,0 +1,76 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMessageReply(t *testing.T) {
+	for _, tt := range []struct {
+		req  *Message
+		want *Message
+		echo *Message // Reply with the first argument echoed
+	}{
+		{
+			NewMessage("/get", int32(7), "gain"),
+			NewMessage("/value", float32(0.5)),
+			NewMessage("/value", int32(7), float32(0.5)),
+		},
+		{
+			NewMessage("/get", []byte{1}),
+			NewMessage("/value", float32(0.5)),
+			NewMessage("/value", []byte{1}, float32(0.5)),
+		},
+		{
+			NewMessage("/get"),
+			NewMessage("/value", float32(0.5)),
+			NewMessage("/value", float32(0.5)),
+		},
+	} {
+		if got := tt.req.Reply("/value", float32(0.5)); !got.Equals(tt.want) {
+			t.Errorf("%v: Reply() = %v, want = %v", tt.req, got, tt.want)
+		}
+		if got := tt.req.ReplyEcho("/value", float32(0.5)); !got.Equals(tt.echo) {
+			t.Errorf("%v: ReplyEcho() = %v, want = %v", tt.req, got, tt.echo)
+		}
+	}
+}
+
+func TestServerRespond(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	server := &Server{}
+	if err := server.Handle("/get", AddrHandlerFunc(func(msg *Message, addr net.Addr) {
+		errs <- server.Respond(addr, msg.ReplyEcho("/value", int32(1)))
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Respond(conn.LocalAddr(), NewMessage("/value")); err == nil {
+		t.Error("Respond() expected an error before serving")
+	}
+	go server.Serve(conn)
+	defer server.CloseConnection()
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := client.Request(ctx, NewMessage("/get", "gain"), "/value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := NewMessage("/value", "gain", int32(1)); !reply.Equals(want) {
+		t.Errorf("reply = %v, want = %v", reply, want)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Respond() error = %v", err)
+	}
+
+	if err := server.Respond(nil, NewMessage("/value")); err == nil {
+		t.Error("Respond(nil) expected an error")
+	}
+}
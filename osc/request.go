@@ -0,0 +1,124 @@
+package osc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Request sends the request message and waits for a reply, i.e. a message
+// whose address matches the OSC address pattern replyAddr, which is returned.
+// The reply must be sent back to the local address the request was sent
+// from by the target of the client. Replies contained in bundles are
+// accepted as well, regardless of their time tags. If several replies
+// arrive, the first one is returned and the others are discarded, as are
+// other packets.
+//
+// Request waits until ctx is done, so use a context with a deadline. If no
+// reply arrives, the context's error is returned. If retransmissions are
+// configured with SetSendRetries, the request is sent again after every
+// interval without a reply, up to the given number of times. Requests are
+// only supported by UDP clients.
+func (c *Client) Request(ctx context.Context, req *Message, replyAddr string) (*Message, error) {
+	if err := ValidatePattern(replyAddr); err != nil {
+		return nil, err
+	}
+	if c.unixPath != "" || c.pipe != nil {
+		return nil, errors.New("requests are only supported over UDP")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := c.encode(req)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Abort the current read by moving the read deadline into the past as
+	// soon as the context is done.
+	readDone := make(chan struct{})
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Unix(1, 0))
+		case <-readDone:
+		}
+	}()
+	defer func() {
+		close(readDone)
+		<-watchDone
+	}()
+
+	buf := make([]byte, DefaultReadBufferSize)
+	var resend time.Time // Time of the next transmission, zero if none
+	for sent := 0; ; {
+		if sent == 0 || !resend.IsZero() && !time.Now().Before(resend) {
+			if err := c.write(conn, data); err != nil {
+				return nil, err
+			}
+			sent++
+			resend = time.Time{}
+			if sent <= c.retries {
+				resend = time.Now().Add(c.interval)
+			}
+		}
+
+		// Check the context after setting the deadline, so that the
+		// deadline set once it is done isn't overwritten
+		if err := conn.SetReadDeadline(resend); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+
+		p, err := parsePacketBytes(buf[:n])
+		if err != nil {
+			continue
+		}
+		if reply := findReply(p, replyAddr); reply != nil {
+			return reply, nil
+		}
+	}
+}
+
+// findReply returns the first message in p whose address matches the
+// pattern replyAddr, or nil if there is none.
+func findReply(p Packet, replyAddr string) *Message {
+	switch t := p.(type) {
+	case *Message:
+		if matchAddress(replyAddr, t.Address) {
+			return t
+		}
+	case *Bundle:
+		for _, msg := range t.Messages {
+			if reply := findReply(msg, replyAddr); reply != nil {
+				return reply
+			}
+		}
+		for _, b := range t.Bundles {
+			if reply := findReply(b, replyAddr); reply != nil {
+				return reply
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// replier answers every request received on conn, starting with the given
+// one, with the packets returned by reply. It returns the number of requests
+// received through the channel once conn is closed.
+func replier(conn net.PacketConn, first int, reply func(req *Message) []Packet) <-chan int {
+	count := make(chan int, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		n := 0
+		for {
+			size, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				count <- n
+				return
+			}
+			n++
+			p, err := ParsePacket(string(buf[:size]))
+			if err != nil || n < first {
+				continue
+			}
+			for _, r := range reply(p.(*Message)) {
+				data, _ := r.MarshalBinary()
+				conn.WriteTo(data, addr)
+			}
+		}
+	}()
+	return count
+}
+
+func TestClientRequest(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	replier(conn, 1, func(req *Message) []Packet {
+		bundle := NewBundle(time.Now())
+		bundle.Append(NewMessage("/config/value", req.Arguments[0], int32(1)))
+		return []Packet{NewMessage("/other"), bundle, NewMessage("/config/value", "second")}
+	})
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := client.Request(ctx, NewMessage("/config/get", "gain"), "/config/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := NewMessage("/config/value", "gain", int32(1)); !reply.Equals(want) {
+		t.Errorf("Request() = %v, want = %v", reply, want)
+	}
+
+	if _, err := client.Request(ctx, NewMessage("/config/get"), "/config/[a"); err == nil {
+		t.Error("expected an error for an invalid reply address")
+	}
+}
+
+func TestClientRequestNoReply(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	replier(conn, 1, func(req *Message) []Packet {
+		return []Packet{NewMessage("/other")}
+	})
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Request(ctx, NewMessage("/config/get"), "/config/value"); err != context.DeadlineExceeded {
+		t.Errorf("Request() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClientRequestRetries(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the third request is answered
+	count := replier(conn, 3, func(req *Message) []Packet {
+		return []Packet{NewMessage("/config/value")}
+	})
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	client.SetSendRetries(2, 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Request(ctx, NewMessage("/config/get"), "/config/value"); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if n := <-count; n != 3 {
+		t.Errorf("server received %d requests, want = 3", n)
+	}
+}
@@ -0,0 +1,129 @@
+package osc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// SLIP special characters, see RFC 1055.
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+)
+
+// WritePacketSLIP writes the packet to w, framed with SLIP (RFC 1055) as
+// specified by OSC 1.1 for stream transports. The packet is preceded and
+// followed by an END byte, so multiple packets can be written to the same
+// stream and decoded with a SLIPDecoder. It returns the number of bytes
+// written.
+func WritePacketSLIP(w io.Writer, p Packet) (int64, error) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	var frame bytes.Buffer
+	frame.Grow(len(data) + 2)
+	frame.WriteByte(slipEnd)
+	for _, b := range data {
+		switch b {
+		case slipEnd:
+			frame.Write([]byte{slipEsc, slipEscEnd})
+		case slipEsc:
+			frame.Write([]byte{slipEsc, slipEscEsc})
+		default:
+			frame.WriteByte(b)
+		}
+	}
+	frame.WriteByte(slipEnd)
+	n, err := w.Write(frame.Bytes())
+	return int64(n), err
+}
+
+// SLIPDecoder decodes SLIP framed OSC packets from a stream. The bytes read
+// from the stream are passed to Write in chunks of any size. The decoder
+// buffers incomplete frames, including escape sequences, until the next
+// Write, and decoded packets are returned by Next.
+//
+// A malformed frame, i.e. one containing an invalid escape sequence or
+// something else than an OSC packet, is discarded and causes Write to
+// return an error. Decoding resumes with the next frame, so the decoder can
+// be used further after an error.
+type SLIPDecoder struct {
+	frame   []byte
+	escaped bool     // Whether the previous byte was ESC
+	invalid bool     // Whether the current frame is discarded
+	packets []Packet // Decoded packets not yet returned by Next
+}
+
+// Write decodes the given bytes. It always consumes all of data and returns
+// len(data) together with the error of the first malformed frame ending in
+// data, if any.
+func (d *SLIPDecoder) Write(data []byte) (int, error) {
+	var firstErr error
+	fail := func(err error) {
+		d.frame = d.frame[:0]
+		d.escaped = false
+		d.invalid = true
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, b := range data {
+		if b == slipEnd {
+			if d.escaped && !d.invalid {
+				fail(fmt.Errorf("%w: SLIP END after ESC", ErrInvalidPacket))
+			}
+			if !d.invalid && len(d.frame) > 0 {
+				if p, err := parsePacketBytes(d.frame); err != nil {
+					fail(err)
+				} else {
+					// The packet may refer to the bytes of the frame
+					d.packets = append(d.packets, p)
+					d.frame = nil
+				}
+			}
+			d.frame = d.frame[:0]
+			d.escaped = false
+			d.invalid = false
+			continue
+		}
+		if d.invalid {
+			continue
+		}
+
+		if d.escaped {
+			d.escaped = false
+			switch b {
+			case slipEscEnd:
+				b = slipEnd
+			case slipEscEsc:
+				b = slipEsc
+			default:
+				fail(fmt.Errorf("%w: invalid SLIP escape sequence 0x%02X 0x%02X", ErrInvalidPacket, slipEsc, b))
+				continue
+			}
+		} else if b == slipEsc {
+			d.escaped = true
+			continue
+		}
+		d.frame = append(d.frame, b)
+	}
+	return len(data), firstErr
+}
+
+// Next returns the next decoded packet. It returns false if all packets
+// decoded so far have been returned.
+func (d *SLIPDecoder) Next() (Packet, bool) {
+	if len(d.packets) == 0 {
+		return nil, false
+	}
+	p := d.packets[0]
+	d.packets[0] = nil
+	d.packets = d.packets[1:]
+	return p, true
+}
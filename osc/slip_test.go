@@ -0,0 +1,89 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSLIPDecoder(t *testing.T) {
+	packets := []Packet{
+		NewMessage("/a", int32(1)),
+		NewMessage("/blob", []byte{slipEnd, slipEsc, slipEscEnd, slipEnd}),
+		NewMessage("/b", "\xc0\xdb"),
+	}
+	var stream bytes.Buffer
+	for _, p := range packets {
+		if _, err := WritePacketSLIP(&stream, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := stream.Bytes()
+	if bytes.Count(data, []byte{slipEnd}) != 2*len(packets) {
+		t.Fatalf("unescaped END bytes in %q", data)
+	}
+
+	for _, chunkSize := range []int{1, 2, 3, 7, len(data)} {
+		var d SLIPDecoder
+		var got []Packet
+		for i := 0; i < len(data); i += chunkSize {
+			end := i + chunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			if _, err := d.Write(data[i:end]); err != nil {
+				t.Fatalf("chunk size %d: Write() error = %v", chunkSize, err)
+			}
+			for p, ok := d.Next(); ok; p, ok = d.Next() {
+				got = append(got, p)
+			}
+		}
+		if len(got) != len(packets) {
+			t.Fatalf("chunk size %d: decoded %d packets, want = %d", chunkSize, len(got), len(packets))
+		}
+		for i, p := range got {
+			if !p.(*Message).Equals(packets[i].(*Message)) {
+				t.Errorf("chunk size %d: packet %d = %v, want = %v", chunkSize, i, p, packets[i])
+			}
+		}
+	}
+}
+
+func TestSLIPDecoderErrors(t *testing.T) {
+	var valid bytes.Buffer
+	if _, err := WritePacketSLIP(&valid, NewMessage("/ok")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		data string
+	}{
+		{"invalid_escape", "\xc0/a\x00\x00\xdb\x01,\x00\x00\x00\xc0"},
+		{"end_after_escape", "\xc0/a\x00\x00,\x00\x00\x00\xdb\xc0"},
+		{"invalid_packet", "\xc0abc\x00\xc0"},
+	} {
+		// Feed the data byte by byte, the error must be returned once and the
+		// following frame must still be decoded
+		var d SLIPDecoder
+		errs := 0
+		for _, b := range append([]byte(tt.data), valid.Bytes()...) {
+			if _, err := d.Write([]byte{b}); err != nil {
+				errs++
+			}
+		}
+		if errs != 1 {
+			t.Errorf("%s: got %d errors, want = 1", tt.desc, errs)
+		}
+		p, ok := d.Next()
+		if !ok {
+			t.Errorf("%s: following packet wasn't decoded", tt.desc)
+			continue
+		}
+		if got := p.(*Message).Address; got != "/ok" {
+			t.Errorf("%s: decoded %q, want = %q", tt.desc, got, "/ok")
+		}
+		if _, ok := d.Next(); ok {
+			t.Errorf("%s: decoded more than one packet", tt.desc)
+		}
+	}
+}
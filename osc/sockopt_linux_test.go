@@ -0,0 +1,34 @@
+package osc
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// checkKeepAlivePeriod checks that TCP keep-alive probes are sent on c after
+// it has been idle for the given period.
+func checkKeepAlivePeriod(t *testing.T, c net.Conn, period time.Duration) {
+	t.Helper()
+	rc, err := c.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var enabled, idle int
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		if enabled, serr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); serr != nil {
+			return
+		}
+		idle, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if enabled == 0 || time.Duration(idle)*time.Second != period {
+		t.Errorf("keep-alive = %t, %v, want = true, %v", enabled != 0, time.Duration(idle)*time.Second, period)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package osc
+
+import "syscall"
+
+// sockoptSupported reports whether socket options can be set.
+const sockoptSupported = false
+
+// setBroadcast always fails on platforms without socket options.
+func setBroadcast(rc syscall.RawConn, enable bool) error {
+	return errSockoptUnsupported
+}
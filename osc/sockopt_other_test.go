@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package osc
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// checkKeepAlivePeriod does nothing, since the keep-alive period of a
+// connection can only be read on Linux.
+func checkKeepAlivePeriod(t *testing.T, c net.Conn, period time.Duration) {}
@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package osc
+
+import "syscall"
+
+// reusePortSupported reports whether SO_REUSEPORT can be set.
+const reusePortSupported = false
+
+// setReusePort always fails on platforms without SO_REUSEPORT.
+func setReusePort(rc syscall.RawConn) error {
+	return errSockoptUnsupported
+}
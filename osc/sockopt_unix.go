@@ -0,0 +1,25 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package osc
+
+import "syscall"
+
+// sockoptSupported reports whether socket options can be set.
+const sockoptSupported = true
+
+// setSockoptInt sets an integer socket option on the given raw connection.
+func setSockoptInt(rc syscall.RawConn, level, opt, value int) error {
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), level, opt, value)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// setBroadcast enables or disables SO_BROADCAST on the given raw connection.
+func setBroadcast(rc syscall.RawConn, enable bool) error {
+	return setSockoptInt(rc, syscall.SOL_SOCKET, syscall.SO_BROADCAST, boolint(enable))
+}
@@ -0,0 +1,39 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package osc
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestClientBroadcastSockopt(t *testing.T) {
+	client := NewClient("127.0.0.1", 9)
+	if err := client.SetBroadcast(true); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := client.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rc, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value int
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		value, serr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if value == 0 {
+		t.Error("SO_BROADCAST isn't set on the connection")
+	}
+}
@@ -0,0 +1,60 @@
+package osc
+
+import "sync/atomic"
+
+// Stats holds the counters of a Server and its Dispatcher.
+type Stats struct {
+	PacketsReceived    uint64 // Datagrams or TCP frames read from the connections
+	ParseErrors        uint64 // Packets that couldn't be parsed or were truncated
+	PacketsDropped     uint64 // Packets dropped due to the rate limit
+	BundlesRejected    uint64 // Bundles dropped due to DisallowBundles
+	TruncatedMessages  uint64 // Messages with missing arguments, if allowed
+	MessagesDispatched uint64 // Messages passed to at least one handler
+	MessagesUnhandled  uint64 // Messages without any matching handler
+}
+
+// StatsProvider is implemented by dispatchers that count dispatched messages.
+// The Server includes their counters in its Stats.
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// serverStats holds the counters of a Server.
+type serverStats struct {
+	packetsReceived   atomic.Uint64
+	parseErrors       atomic.Uint64
+	packetsDropped    atomic.Uint64
+	bundlesRejected   atomic.Uint64
+	truncatedMessages atomic.Uint64
+}
+
+// dispatcherStats holds the counters of a StandardDispatcher.
+type dispatcherStats struct {
+	messagesDispatched atomic.Uint64
+	messagesUnhandled  atomic.Uint64
+}
+
+// Stats returns the current counters of the server. If the server's
+// Dispatcher implements StatsProvider, its message counters are included.
+// The counters are updated atomically, so Stats can be called at any time.
+func (s *Server) Stats() Stats {
+	var st Stats
+	if p, ok := s.Dispatcher.(StatsProvider); ok {
+		st = p.Stats()
+	}
+	st.PacketsReceived = s.stats.packetsReceived.Load()
+	st.ParseErrors = s.stats.parseErrors.Load()
+	st.PacketsDropped = s.stats.packetsDropped.Load()
+	st.BundlesRejected = s.stats.bundlesRejected.Load()
+	st.TruncatedMessages = s.stats.truncatedMessages.Load()
+	return st
+}
+
+// Stats returns the message counters of the dispatcher. Implements the
+// StatsProvider interface.
+func (s *StandardDispatcher) Stats() Stats {
+	return Stats{
+		MessagesDispatched: s.stats.messagesDispatched.Load(),
+		MessagesUnhandled:  s.stats.messagesUnhandled.Load(),
+	}
+}
@@ -0,0 +1,150 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServerStats(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/handled", func(msg *Message) {}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d, RateLimit: 0.001, RateBurst: 3}
+
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	for _, data := range [][]byte{
+		mustMarshal(t, NewMessage("/handled")),
+		mustMarshal(t, NewMessage("/unhandled")),
+		[]byte("/bad\x00\x00\x00\x00x\x00\x00\x00"),
+		mustMarshal(t, NewMessage("/handled")),
+		mustMarshal(t, NewMessage("/handled")),
+	} {
+		if err := client.SendRaw(data); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		server.ServeOne(ctx, conn)
+		cancel()
+	}
+
+	want := Stats{
+		PacketsReceived:    5,
+		ParseErrors:        1,
+		PacketsDropped:     1,
+		MessagesDispatched: 2,
+		MessagesUnhandled:  1,
+	}
+	if got := server.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want = %+v", got, want)
+	}
+}
+
+func TestServerDisallowBundles(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var got []string
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("*", func(msg *Message) {
+		got = append(got, msg.Address)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d, DisallowBundles: true}
+
+	bundle := NewBundle(time.Time{})
+	if err := bundle.Append(NewMessage("/bundled")); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	for _, p := range []Packet{NewMessage("/first"), bundle, NewMessage("/second")} {
+		if err := client.Send(p); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := server.ServeOne(ctx, conn)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := []string{"/first", "/second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched = %v, want = %v", got, want)
+	}
+	if got := server.Stats().BundlesRejected; got != 1 {
+		t.Errorf("BundlesRejected = %d, want = 1", got)
+	}
+}
+
+func TestServeDropsInvalidPackets(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	received := make(chan struct{})
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/valid", func(msg *Message) {
+		close(received)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d, ReadBufferSize: 1024}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- server.ServeContext(ctx, conn) }()
+
+	invalid := [][]byte{
+		[]byte("garbage!"),
+		[]byte("/a"),
+		[]byte("/a\x00\x00,i\x00\x00"),
+		nestedBundles(t, DefaultMaxBundleDepth+1),
+		mustMarshal(t, NewMessage("/valid", make([]byte, 2048))),
+	}
+	client := NewClient("127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port)
+	for _, data := range append(invalid, mustMarshal(t, NewMessage("/valid"))) {
+		if err := client.SendRaw(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-received:
+	case err := <-errc:
+		t.Fatalf("ServeContext() error = %v after invalid packets", err)
+	case <-time.After(time.Second):
+		t.Fatal("valid message not dispatched")
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("ServeContext() error = %v, want = %v", err, context.Canceled)
+	}
+	if got, want := server.Stats().ParseErrors, uint64(len(invalid)); got != want {
+		t.Errorf("ParseErrors = %d, want = %d", got, want)
+	}
+}
+
+func mustMarshal(t *testing.T, p Packet) []byte {
+	t.Helper()
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
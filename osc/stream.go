@@ -0,0 +1,198 @@
+package osc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxStreamPacketSize is the maximum size of a packet read by ReadPacket.
+const maxStreamPacketSize = 1 << 24
+
+// Verify that Message and Bundle implement the io.WriterTo interface.
+var (
+	_ io.WriterTo = (*Message)(nil)
+	_ io.WriterTo = (*Bundle)(nil)
+)
+
+// WriteTo writes the binary encoding of the message to w. It returns the
+// number of bytes written. Implements the io.WriterTo interface.
+func (msg *Message) WriteTo(w io.Writer) (int64, error) {
+	return writePacketTo(w, msg)
+}
+
+// WriteTo writes the binary encoding of the bundle to w. It returns the
+// number of bytes written. Implements the io.WriterTo interface.
+func (b *Bundle) WriteTo(w io.Writer) (int64, error) {
+	return writePacketTo(w, b)
+}
+
+func writePacketTo(w io.Writer, p Packet) (int64, error) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// WritePacket writes the packet to w, prefixed with its size as int32. This
+// is the framing used by the OSC 1.0 stream transport, so multiple packets
+// can be written to the same stream and read with ReadPacket. It returns the
+// number of bytes written, including the size prefix.
+func WritePacket(w io.Writer, p Packet) (int64, error) {
+	frame, err := framePacket(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(frame)
+	return int64(n), err
+}
+
+// framePacket returns the binary encoding of p, prefixed with its size.
+func framePacket(p Packet) ([]byte, error) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	return frame, nil
+}
+
+// ReadPacket reads a single packet written by WritePacket, i.e. prefixed with
+// its size as int32, from r. It returns io.EOF if r is at its end and
+// io.ErrUnexpectedEOF if it ends within a packet.
+func ReadPacket(r io.Reader) (Packet, error) {
+	data, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	return parsePacketBytes(data)
+}
+
+// readFrame reads the data of a single packet prefixed with its size as
+// int32 from r, without parsing it.
+func readFrame(r io.Reader) ([]byte, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 1 || size > maxStreamPacketSize {
+		return nil, fmt.Errorf("%w: invalid packet size %d", ErrInvalidPacket, size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// ParseStream parses the OSC message read from r and calls fn for every
+// argument as soon as it has been decoded, instead of collecting all
+// arguments in a Message. This allows processing messages with large arrays
+// without holding all of their elements in memory. fn is called with the
+// address of the message, the type tag of the argument and its value, of
+// the same type as in the Arguments of a parsed Message. The elements of
+// arrays are passed one by one, enclosed by calls with the tags '[' and ']'
+// and a nil value. Every blob is read as a whole and may be at most 16 MiB
+// long.
+//
+// r must contain a message, bundles aren't supported. Since r is read with
+// a buffer, more bytes than the message may be read from it. For a message
+// of an unknown type tag, whose size is unknown, all data up to the end of r
+// is passed as UnknownArg, like by ParsePacket. Parsing stops at the first
+// error returned by fn, which is returned by ParseStream. Other errors wrap
+// ErrShortPacket if r ends prematurely or ErrInvalidPacket if the message
+// is malformed.
+func ParseStream(r io.Reader, fn func(addr string, tag byte, v interface{}) error) error {
+	reader, ok := r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+	shortErr := func(err error) error {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: %v", ErrShortPacket, err)
+		}
+		return err
+	}
+
+	addr, _, err := readPaddedString(reader)
+	if err != nil {
+		return shortErr(err)
+	}
+	if !strings.HasPrefix(addr, "/") {
+		return fmt.Errorf("%w: message must start with '/'", ErrInvalidPacket)
+	}
+	typetags, _, err := readPaddedString(reader)
+	if err == io.EOF {
+		return fmt.Errorf("%w: missing type tag string of message %s", ErrInvalidPacket, addr)
+	}
+	if err != nil {
+		return shortErr(err)
+	}
+	if typetags == "" {
+		return nil
+	}
+	if typetags[0] != ',' {
+		return fmt.Errorf("%w: unsupported type tag string %s", ErrInvalidPacket, typetags)
+	}
+	if err := checkArrayTags(typetags); err != nil {
+		return err
+	}
+
+	unknown := false
+	for _, c := range []byte(typetags[1:]) {
+		var arg interface{}
+		switch {
+		case c == '[' || c == ']':
+		case unknown:
+			arg = UnknownArg{Tag: c}
+		default:
+			arg, _, err = readArgument(reader, c, binary.BigEndian, false)
+			if err == errUnknownTypeTag {
+				var data []byte
+				if data, err = io.ReadAll(reader); err != nil {
+					return err
+				}
+				arg = UnknownArg{Tag: c, Data: data}
+				unknown = true
+			} else if err != nil {
+				return shortErr(err)
+			}
+		}
+		if err := fn(addr, c, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkArrayTags returns an error if the arrays in the type tag string aren't
+// properly enclosed in '[' and ']'.
+func checkArrayTags(typetags string) error {
+	depth := 0
+	for _, c := range []byte(typetags) {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				return fmt.Errorf("%w: unexpected ']' in type tag string", ErrInvalidPacket)
+			}
+			depth--
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("%w: unclosed array in type tag string", ErrInvalidPacket)
+	}
+	return nil
+}
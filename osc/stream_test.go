@@ -0,0 +1,196 @@
+package osc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestPacketWriteTo(t *testing.T) {
+	bundle := NewBundle(time.Now())
+	bundle.Append(NewMessage("/b", "c"))
+	for _, p := range []Packet{NewMessage("/a", int32(1), "foo"), bundle} {
+		want, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		n, err := p.(io.WriterTo).WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(want)) {
+			t.Errorf("WriteTo() = %d, want = %d", n, len(want))
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("WriteTo() wrote %q, want = %q", buf.Bytes(), want)
+		}
+	}
+}
+
+func TestWriteReadPacket(t *testing.T) {
+	var buf bytes.Buffer
+	msgs := []*Message{NewMessage("/a", int32(1)), NewMessage("/b", "two", float32(3))}
+	for _, msg := range msgs {
+		n, err := WritePacket(&buf, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := msg.MarshalBinary()
+		if n != int64(4+len(data)) {
+			t.Errorf("WritePacket() = %d, want = %d", n, 4+len(data))
+		}
+	}
+
+	for _, want := range msgs {
+		p, err := ReadPacket(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.(*Message).Equals(want) {
+			t.Errorf("ReadPacket() = %v, want = %v", p, want)
+		}
+	}
+	if _, err := ReadPacket(&buf); err != io.EOF {
+		t.Errorf("ReadPacket() error = %v, want = %v", err, io.EOF)
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		data []byte
+		err  error
+	}{
+		{"short_size", []byte{0, 0}, io.ErrUnexpectedEOF},
+		{"short_packet", []byte{0, 0, 0, 8, '/', 'a', 0, 0}, io.ErrUnexpectedEOF},
+		{"zero_size", []byte{0, 0, 0, 0}, nil},
+		{"negative_size", []byte{255, 255, 255, 255}, nil},
+	} {
+		_, err := ReadPacket(bytes.NewReader(tt.data))
+		if err == nil {
+			t.Errorf("%s: ReadPacket() expected an error", tt.desc)
+			continue
+		}
+		if tt.err != nil && err != tt.err {
+			t.Errorf("%s: ReadPacket() error = %v, want = %v", tt.desc, err, tt.err)
+		}
+	}
+}
+
+// streamedArg is an argument passed to the callback of ParseStream.
+type streamedArg struct {
+	tag byte
+	v   interface{}
+}
+
+func TestParseStream(t *testing.T) {
+	tag := *NewTimetagFromTimetag(1)
+	msg := NewMessage("/stream", int32(1), int64(2), float32(3), float64(4), "five",
+		[]byte{6}, tag, nil, true, false, []interface{}{int32(7), []interface{}{"eight"}})
+	want := []streamedArg{
+		{'i', int32(1)}, {'h', int64(2)}, {'f', float32(3)}, {'d', float64(4)}, {'s', "five"},
+		{'b', []byte{6}}, {'t', tag}, {'N', nil}, {'T', true}, {'F', false},
+		{'[', nil}, {'i', int32(7)}, {'[', nil}, {'s', "eight"}, {']', nil}, {']', nil},
+	}
+
+	var got []streamedArg
+	err := ParseStream(bytes.NewReader(mustMarshal(t, msg)), func(addr string, tag byte, v interface{}) error {
+		if addr != "/stream" {
+			t.Errorf("addr = %s, want = /stream", addr)
+		}
+		got = append(got, streamedArg{tag, v})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStream() passed %v, want = %v", got, want)
+	}
+
+	// An error of the callback aborts parsing
+	errStop := errors.New("stop")
+	calls := 0
+	err = ParseStream(bytes.NewReader(mustMarshal(t, msg)), func(string, byte, interface{}) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Errorf("ParseStream() error = %v after %d calls, want = %v after 1 call", err, calls, errStop)
+	}
+}
+
+func TestParseStreamShortReads(t *testing.T) {
+	msg := NewMessage("/ab", "x", int32(7), []byte{1})
+	want := []streamedArg{{'s', "x"}, {'i', int32(7)}, {'b', []byte{1}}}
+	for _, tt := range []struct {
+		desc   string
+		reader func(r io.Reader) io.Reader
+	}{
+		{"one_byte", iotest.OneByteReader},
+		{"half", iotest.HalfReader},
+		{"data_err", iotest.DataErrReader},
+	} {
+		var got []streamedArg
+		r := tt.reader(bytes.NewReader(mustMarshal(t, msg)))
+		err := ParseStream(r, func(addr string, tag byte, v interface{}) error {
+			got = append(got, streamedArg{tag, v})
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: ParseStream() error = %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ParseStream() passed %v, want = %v", tt.desc, got, want)
+		}
+	}
+}
+
+func TestParseStreamUnknownTypeTag(t *testing.T) {
+	data := "/a" + nulls(2) + ",ixs" + nulls(4) + "\x00\x00\x00\x01rest"
+	var got []streamedArg
+	err := ParseStream(bytes.NewReader([]byte(data)), func(addr string, tag byte, v interface{}) error {
+		got = append(got, streamedArg{tag, v})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []streamedArg{{'i', int32(1)}, {'x', UnknownArg{Tag: 'x', Data: []byte("rest")}}, {'s', UnknownArg{Tag: 's'}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStream() passed %v, want = %v", got, want)
+	}
+}
+
+func TestParseStreamErrors(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		data string
+		err  error
+	}{
+		{"empty", "", ErrShortPacket},
+		{"bundle", "#bundle" + nulls(1) + nulls(8), ErrInvalidPacket},
+		{"missing_type_tags", "/a" + nulls(2), ErrInvalidPacket},
+		{"truncated_int", "/a" + nulls(2) + ",i" + nulls(2) + "\x00\x01", ErrShortPacket},
+		{"truncated_blob", "/a" + nulls(2) + ",b" + nulls(2) + "\x00\x00\x00\x08abcd", ErrShortPacket},
+		{"truncated_blob_padding", "/a" + nulls(2) + ",b" + nulls(2) + "\x00\x00\x00\x01x" + nulls(1), ErrShortPacket},
+		{"truncated_string_padding", "/a" + nulls(2) + ",s" + nulls(2) + "abcde" + nulls(1), ErrShortPacket},
+		{"huge_blob", "/a" + nulls(2) + ",b" + nulls(2) + "\x7f\xff\xff\xff", ErrInvalidPacket},
+		{"unclosed_array", "/a" + nulls(2) + ",[i" + nulls(1) + "\x00\x00\x00\x01", ErrInvalidPacket},
+		{"unexpected_bracket", "/a" + nulls(2) + ",]" + nulls(2), ErrInvalidPacket},
+	} {
+		err := ParseStream(bytes.NewReader([]byte(tt.data)), func(string, byte, interface{}) error {
+			return nil
+		})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: ParseStream() error = %v, want = %v", tt.desc, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package osc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by ServeTCP after its context is done.
+var ErrServerClosed = errors.New("osc: server closed")
+
+// ServeTCP accepts TCP connections on l and dispatches the OSC packets read
+// from them until ctx is done. The packets of a connection must be framed
+// with their size as int32, like written by WritePacket, and are dispatched
+// in the order they are received. When ctx is done, ServeTCP stops accepting
+// connections, closes l and all active connections and returns
+// ErrServerClosed once the connections have been handled.
+func (s *Server) ServeTCP(ctx context.Context, l net.Listener) error {
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// Unblock Accept when the context is done
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	var tempDelay time.Duration
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if max := 1 * time.Second; tempDelay > max {
+					tempDelay = max
+				}
+				s.logf("osc: accept error: %v; retrying in %v", err, tempDelay)
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+		if s.TCPKeepAlive != 0 {
+			if err := setKeepAlive(c, s.TCPKeepAlive); err != nil {
+				s.logf("osc: setting keep-alive for %v: %v", c.RemoteAddr(), err)
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.ServeConn(ctx, c); err != nil && ctx.Err() == nil {
+				s.logf("osc: read error from %v: %v", c.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ServeConn reads OSC packets from the stream connection c, e.g. a connection
+// accepted from a TCP or TLS listener, and dispatches them in the order they
+// are received. The packets must be framed like in ServeTCP and are parsed
+// with the server's ParseOptions. Packets that can't be parsed are counted
+// as parse errors, logged and dropped, while the connection is served on.
+// ServeConn returns nil once the peer closes the connection, the context's
+// error once ctx is done and the read error otherwise. c is always closed on
+// return.
+func (s *Server) ServeConn(ctx context.Context, c net.Conn) error {
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		c.Close()
+	}()
+
+	addr := c.RemoteAddr()
+	r := bufio.NewReader(c)
+	for {
+		if err := s.setConnReadDeadline(ctx, c); err != nil {
+			return err
+		}
+		data, err := readFrame(r)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if errors.Is(err, ErrInvalidPacket) {
+				// The frame size is invalid, so the stream can't be read on
+				s.stats.parseErrors.Add(1)
+			}
+			return err
+		}
+		s.stats.packetsReceived.Add(1)
+		if s.OnRawPacket != nil {
+			s.OnRawPacket(data, addr)
+		}
+		p, err := s.ParseOptions.parse(data)
+		if err != nil {
+			// The frame has been read as a whole, so the stream is still
+			// in sync and the next packet can be read
+			s.stats.parseErrors.Add(1)
+			s.logf("osc: dropping packet from %v: %v", addr, err)
+			continue
+		}
+		if s.KeepRawBytes {
+			attachRaw(p, data)
+		}
+		s.countTruncated(p, addr)
+		if !s.acceptFrom(addr) || s.rejectBundle(p, addr) {
+			continue
+		}
+		s.dispatch(p, addr)
+	}
+}
+
+// keepAliveConn is implemented by connections supporting TCP keep-alive, like
+// net.TCPConn.
+type keepAliveConn interface {
+	SetKeepAlive(keepalive bool) error
+	SetKeepAlivePeriod(d time.Duration) error
+}
+
+// setKeepAlive enables TCP keep-alive probes with the given period on c, or
+// disables them if period is negative.
+func setKeepAlive(c net.Conn, period time.Duration) error {
+	kc, ok := c.(keepAliveConn)
+	if !ok {
+		return fmt.Errorf("keep-alive isn't supported for %T", c)
+	}
+	if period < 0 {
+		return kc.SetKeepAlive(false)
+	}
+	if err := kc.SetKeepAlive(true); err != nil {
+		return err
+	}
+	return kc.SetKeepAlivePeriod(period)
+}
+
+// setConnReadDeadline sets the read deadline of c to the earlier of the
+// context's deadline and the server's ReadTimeout, if any.
+func (s *Server) setConnReadDeadline(ctx context.Context, c net.Conn) error {
+	deadline, ok := ctx.Deadline()
+	if s.ReadTimeout != 0 {
+		if d := time.Now().Add(s.ReadTimeout); !ok || d.Before(deadline) {
+			deadline, ok = d, true
+		}
+	}
+	if !ok {
+		return nil
+	}
+	return c.SetReadDeadline(deadline)
+}
+
+// ConnState is the state of the connection of a TCPClient.
+type ConnState int
+
+const (
+	// StateDisconnected means that the client has no connection, either
+	// because it hasn't sent anything yet or because the connection failed.
+	StateDisconnected ConnState = iota
+
+	// StateConnecting means that the client is dialing its target.
+	StateConnecting
+
+	// StateConnected means that the client has an open connection.
+	StateConnected
+
+	// StateClosed means that the client has been closed.
+	StateClosed
+)
+
+// String returns the name of the state.
+func (s ConnState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// TCPClient sends OSC packets over a TCP connection, framed like in
+// ServeTCP. The connection is opened by the first Send and kept open. If it
+// fails, the next Send dials again, so a client recovers from a dropped
+// connection by itself. A TCPClient is safe for concurrent use, concurrent
+// sends wait for each other, including for reconnects.
+type TCPClient struct {
+	addr      string
+	attempts  int
+	backoff   time.Duration
+	wtimeout  time.Duration
+	keepAlive time.Duration
+
+	// OnStateChange, if set, is called whenever the state of the connection
+	// changes, e.g. to observe reconnects. It must not call the client.
+	OnStateChange func(state ConnState)
+
+	sem   chan struct{} // Held while sending
+	mu    sync.Mutex    // Guards conn and state
+	conn  net.Conn
+	state ConnState
+}
+
+// NewClientTCP returns a new OSC client that sends packets to the TCP
+// address ip:port, e.g. to a Server serving it with ServeTCP.
+func NewClientTCP(ip string, port int) *TCPClient {
+	return &TCPClient{
+		addr: hostPort(ip, port),
+		sem:  make(chan struct{}, 1),
+	}
+}
+
+// SetReconnect sets the number of times Send dials again if dialing the
+// target fails, waiting backoff before the first attempt and twice as long
+// before every further attempt. By default, Send dials once and returns the
+// error if that fails, leaving it to the next Send to dial again.
+func (c *TCPClient) SetReconnect(attempts int, backoff time.Duration) {
+	c.attempts = attempts
+	c.backoff = backoff
+}
+
+// SetWriteTimeout sets the time after which dialing the target or writing a
+// packet fails with a timeout error, e.g. because the peer stalls and stops
+// reading. The error can be treated as transient: since the packet may have
+// been written partially, the connection is closed and the next Send dials a
+// new one. Zero, the default, disables the timeout.
+func (c *TCPClient) SetWriteTimeout(timeout time.Duration) { c.wtimeout = timeout }
+
+// WriteTimeout returns the timeout set with SetWriteTimeout.
+func (c *TCPClient) WriteTimeout() time.Duration { return c.wtimeout }
+
+// SetKeepAlive sets the interval between TCP keep-alive probes of the
+// connection, which detect a dead peer or network path so that the next Send
+// dials again, e.g. on links over unreliable WANs. A negative period
+// disables keep-alive probes. Zero, the default, keeps the default of the
+// net package, which currently enables probes every 15 seconds on most
+// platforms. The period applies to connections dialed afterwards.
+func (c *TCPClient) SetKeepAlive(period time.Duration) { c.keepAlive = period }
+
+// KeepAlive returns the keep-alive period set with SetKeepAlive.
+func (c *TCPClient) KeepAlive() time.Duration { return c.keepAlive }
+
+// State returns the current state of the connection.
+func (c *TCPClient) State() ConnState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Send sends an OSC Bundle or an OSC Message.
+func (c *TCPClient) Send(packet Packet) error {
+	return c.SendContext(context.Background(), packet)
+}
+
+// SendContext sends an OSC Bundle or an OSC Message like Send. It returns the
+// context's error if ctx is done while waiting for another send or for a
+// reconnect. If writing to an established connection fails, the connection
+// is closed and the packet is sent once more on a new connection, unless the
+// write timed out.
+func (c *TCPClient) SendContext(ctx context.Context, packet Packet) error {
+	frame, err := framePacket(packet)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.sem }()
+
+	for resent := false; ; resent = true {
+		conn, err := c.connect(ctx)
+		if err != nil {
+			return err
+		}
+		if err = c.write(conn, frame); err == nil {
+			return nil
+		}
+		c.disconnect(conn)
+		if ne, ok := err.(net.Error); resent || (ok && ne.Timeout()) {
+			return err
+		}
+	}
+}
+
+// Close closes the connection of the client. Packets can't be sent anymore
+// afterwards.
+func (c *TCPClient) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	c.setState(StateClosed)
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// connect returns the current connection, dialing the target if there is
+// none. It must only be called while holding sem.
+func (c *TCPClient) connect(ctx context.Context) (net.Conn, error) {
+	c.mu.Lock()
+	conn, state := c.conn, c.state
+	c.mu.Unlock()
+	if state == StateClosed {
+		return nil, net.ErrClosed
+	}
+	if conn != nil {
+		return conn, nil
+	}
+
+	c.setState(StateConnecting)
+	d := net.Dialer{Timeout: c.wtimeout, KeepAlive: c.keepAlive}
+	delay := c.backoff
+	for i := 0; ; i++ {
+		conn, err := d.DialContext(ctx, "tcp", c.addr)
+		if err == nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.state == StateClosed {
+				conn.Close()
+				return nil, net.ErrClosed
+			}
+			c.conn = conn
+			c.changeState(StateConnected)
+			return conn, nil
+		}
+		if i >= c.attempts || ctx.Err() != nil {
+			c.setState(StateDisconnected)
+			return nil, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.setState(StateDisconnected)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// write writes frame to conn, applying the write timeout of the client.
+func (c *TCPClient) write(conn net.Conn, frame []byte) error {
+	var deadline time.Time
+	if c.wtimeout > 0 {
+		deadline = time.Now().Add(c.wtimeout)
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	_, err := conn.Write(frame)
+	return err
+}
+
+// disconnect closes the failed connection conn.
+func (c *TCPClient) disconnect(conn net.Conn) {
+	conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		c.conn = nil
+		c.changeState(StateDisconnected)
+	}
+}
+
+// setState changes the state of the connection, unless the client has been
+// closed.
+func (c *TCPClient) setState(state ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.changeState(state)
+}
+
+// changeState is like setState, but c.mu must be held.
+func (c *TCPClient) changeState(state ConnState) {
+	if c.state == state || c.state == StateClosed {
+		return
+	}
+	c.state = state
+	if c.OnStateChange != nil {
+		c.OnStateChange(state)
+	}
+}
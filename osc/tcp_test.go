@@ -0,0 +1,535 @@
+package osc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServeTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *Message, 2)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/tcp", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeTCP(ctx, l)
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, arg := range []int32{1, 2} {
+		if _, err := WritePacket(c, NewMessage("/tcp", arg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []int32{1, 2} {
+		select {
+		case msg := <-received:
+			if got := msg.Arguments[0]; got != want {
+				t.Errorf("argument = %v, want = %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if err != ErrServerClosed {
+			t.Errorf("ServeTCP() error = %v, want = %v", err, ErrServerClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeTCP() didn't return after cancel")
+	}
+
+	// The active connection must have been closed by the server
+	if err := c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Error("expected connection to be closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Error("connection wasn't closed")
+	}
+}
+
+func TestServeTCPContextDeadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	server := &Server{}
+	if err := server.ServeTCP(ctx, l); err != ErrServerClosed {
+		t.Errorf("ServeTCP() error = %v, want = %v", err, ErrServerClosed)
+	}
+}
+
+func TestServeConn(t *testing.T) {
+	received := make(chan *Message, 1)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/conn", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
+
+	// The connection is served until the peer closes it
+	local, remote := net.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeConn(context.Background(), local)
+	}()
+	if _, err := WritePacket(remote, NewMessage("/conn", "pipe")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if got, want := msg.Arguments[0], "pipe"; got != want {
+			t.Errorf("argument = %v, want = %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	remote.Close()
+	if err := <-errc; err != nil {
+		t.Errorf("ServeConn() error = %v, want = nil", err)
+	}
+
+	// ... or until the context is canceled
+	local, remote = net.Pipe()
+	defer remote.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		errc <- server.ServeConn(ctx, local)
+	}()
+	cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("ServeConn() error = %v, want = %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeConn() didn't return after cancel")
+	}
+}
+
+func TestServeConnInvalidPackets(t *testing.T) {
+	received := make(chan *Message, 1)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/conn", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d, ParseOptions: ParseOptions{MaxArguments: 2}}
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeConn(context.Background(), local)
+	}()
+
+	// Packets that can't be parsed are dropped without closing the
+	// connection
+	for _, p := range []Packet{
+		NewMessage("/conn", int32(1), int32(2), int32(3)),
+		NewMessage("/conn", "valid"),
+	} {
+		if _, err := WritePacket(remote, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case msg := <-received:
+		if got, want := msg.Arguments[0], "valid"; got != want {
+			t.Errorf("argument = %v, want = %v", got, want)
+		}
+	case err := <-errc:
+		t.Fatalf("ServeConn() error = %v after invalid packet", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	remote.Close()
+	if err := <-errc; err != nil {
+		t.Errorf("ServeConn() error = %v, want = nil", err)
+	}
+
+	want := Stats{PacketsReceived: 2, ParseErrors: 1, MessagesDispatched: 1}
+	if got := server.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want = %+v", got, want)
+	}
+}
+
+func TestServeConnStats(t *testing.T) {
+	received := make(chan *Message, 1)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("*", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var raw [][]byte
+	server := &Server{
+		Dispatcher:      d,
+		DisallowBundles: true,
+		OnRawPacket: func(data []byte, addr net.Addr) {
+			raw = append(raw, data)
+		},
+	}
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ServeConn(context.Background(), local)
+	}()
+
+	bundle := NewBundle(time.Time{})
+	if err := bundle.Append(NewMessage("/bundled")); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []Packet{bundle, NewMessage("/message")} {
+		if _, err := WritePacket(remote, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case msg := <-received:
+		if got, want := msg.Address, "/message"; got != want {
+			t.Errorf("address = %v, want = %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// An invalid frame size ends the connection
+	if _, err := remote.Write([]byte{0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; !errors.Is(err, ErrInvalidPacket) {
+		t.Errorf("ServeConn() error = %v, want = %v", err, ErrInvalidPacket)
+	}
+
+	want := Stats{
+		PacketsReceived:    2,
+		ParseErrors:        1,
+		BundlesRejected:    1,
+		MessagesDispatched: 1,
+	}
+	if got := server.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want = %+v", got, want)
+	}
+
+	// OnRawPacket gets the packets without their size prefix
+	wantRaw := [][]byte{mustMarshal(t, bundle), mustMarshal(t, NewMessage("/message"))}
+	if !reflect.DeepEqual(raw, wantRaw) {
+		t.Errorf("OnRawPacket() called with %q, want = %q", raw, wantRaw)
+	}
+}
+
+func TestTCPClientReconnect(t *testing.T) {
+	received := make(chan *Message, 16)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/tcp", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	serve := func(l net.Listener) func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			server.ServeTCP(ctx, l)
+			close(done)
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+	stop := serve(l)
+
+	var mu sync.Mutex
+	var states []ConnState
+	client := NewClientTCP("127.0.0.1", addr.Port)
+	client.OnStateChange = func(state ConnState) {
+		mu.Lock()
+		states = append(states, state)
+		mu.Unlock()
+	}
+	if err := client.Send(NewMessage("/tcp", int32(1))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	if got := client.State(); got != StateConnected {
+		t.Errorf("State() = %v, want = %v", got, StateConnected)
+	}
+
+	// Restart the server, which closes the connection of the client. The
+	// first writes to the closed connection may still succeed.
+	stop()
+	if l, err = net.Listen("tcp", addr.String()); err != nil {
+		t.Fatal(err)
+	}
+	stop = serve(l)
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for done := false; !done; {
+		if time.Now().After(deadline) {
+			t.Fatal("client didn't reconnect")
+		}
+		if err := client.Send(NewMessage("/tcp", int32(2))); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-received:
+			done = msg.Arguments[0] == int32(2)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	mu.Lock()
+	want := []ConnState{StateConnecting, StateConnected, StateDisconnected, StateConnecting, StateConnected}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("states = %v, want = %v", states, want)
+	}
+	mu.Unlock()
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(NewMessage("/tcp")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Send() after Close() error = %v, want = %v", err, net.ErrClosed)
+	}
+	if got := client.State(); got != StateClosed {
+		t.Errorf("State() = %v, want = %v", got, StateClosed)
+	}
+}
+
+func TestTCPClientDialError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	client := NewClientTCP("127.0.0.1", port)
+	client.SetReconnect(2, time.Millisecond)
+	if err := client.Send(NewMessage("/tcp")); err == nil {
+		t.Error("expected an error sending to a closed port")
+	}
+	if got := client.State(); got != StateDisconnected {
+		t.Errorf("State() = %v, want = %v", got, StateDisconnected)
+	}
+
+	client.SetReconnect(10, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.SendContext(ctx, NewMessage("/tcp")); err != context.DeadlineExceeded {
+		t.Errorf("SendContext() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestTCPClientWriteTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- c
+		}
+	}()
+
+	client := NewClientTCP("127.0.0.1", l.Addr().(*net.TCPAddr).Port)
+	client.SetWriteTimeout(50 * time.Millisecond)
+	defer client.Close()
+
+	// The first peer never reads, so the send buffers fill up
+	msg := NewMessage("/big", make([]byte, 1<<20))
+	for i := 0; ; i++ {
+		if i == 1000 {
+			t.Fatal("Send() didn't time out")
+		}
+		err := client.Send(msg)
+		if err == nil {
+			continue
+		}
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Fatalf("Send() error = %v, want a timeout", err)
+		}
+		break
+	}
+	stalled := <-conns
+	defer stalled.Close()
+	if got := client.State(); got != StateDisconnected {
+		t.Errorf("State() = %v, want = %v", got, StateDisconnected)
+	}
+
+	// The next packet is sent on a new connection with intact framing
+	if err := client.Send(NewMessage("/next", int32(1))); err != nil {
+		t.Fatal(err)
+	}
+	c := <-conns
+	defer c.Close()
+	if err := c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ReadPacket(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.(*Message).Address; got != "/next" {
+		t.Errorf("received %s, want = /next", got)
+	}
+}
+
+// keepAliveRecorder is a connection recording its keep-alive settings.
+type keepAliveRecorder struct {
+	net.Conn
+	enabled bool
+	period  time.Duration
+}
+
+func (c *keepAliveRecorder) SetKeepAlive(keepalive bool) error {
+	c.enabled = keepalive
+	return nil
+}
+
+func (c *keepAliveRecorder) SetKeepAlivePeriod(d time.Duration) error {
+	c.period = d
+	return nil
+}
+
+func TestSetKeepAlive(t *testing.T) {
+	for _, tt := range []struct {
+		period      time.Duration
+		wantEnabled bool
+		wantPeriod  time.Duration
+	}{
+		{30 * time.Second, true, 30 * time.Second},
+		{-1, false, 0},
+	} {
+		c := &keepAliveRecorder{}
+		if err := setKeepAlive(c, tt.period); err != nil {
+			t.Fatal(err)
+		}
+		if c.enabled != tt.wantEnabled || c.period != tt.wantPeriod {
+			t.Errorf("setKeepAlive(%v) = %t, %v, want = %t, %v",
+				tt.period, c.enabled, c.period, tt.wantEnabled, tt.wantPeriod)
+		}
+	}
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	if err := setKeepAlive(a, time.Second); err == nil {
+		t.Error("setKeepAlive() expected an error for a pipe")
+	}
+}
+
+// keepAliveListener is a listener wrapping the accepted connections in
+// keepAliveRecorders.
+type keepAliveListener struct {
+	net.Listener
+	conns chan *keepAliveRecorder
+}
+
+func (l *keepAliveListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	rc := &keepAliveRecorder{Conn: c}
+	l.conns <- rc
+	return rc, nil
+}
+
+func TestTCPClientKeepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &keepAliveListener{Listener: ln, conns: make(chan *keepAliveRecorder, 1)}
+	received := make(chan *Message, 1)
+	server := &Server{TCPKeepAlive: 10 * time.Second}
+	if err := server.HandleFunc("/alive", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.ServeTCP(ctx, l)
+
+	client := NewClientTCP("127.0.0.1", ln.Addr().(*net.TCPAddr).Port)
+	defer client.Close()
+	client.SetKeepAlive(20 * time.Second)
+	if got := client.KeepAlive(); got != 20*time.Second {
+		t.Errorf("KeepAlive() = %v, want = %v", got, 20*time.Second)
+	}
+	if err := client.Send(NewMessage("/alive")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// The keep-alive period is set before the connection is served, so it
+	// has been set once the message has been received
+	c := <-l.conns
+	if !c.enabled || c.period != 10*time.Second {
+		t.Errorf("accepted connection keep-alive = %t, %v, want = true, %v", c.enabled, c.period, 10*time.Second)
+	}
+	client.mu.Lock()
+	conn := client.conn
+	client.mu.Unlock()
+	checkKeepAlivePeriod(t, conn, 20*time.Second)
+}
@@ -0,0 +1,41 @@
+package osc
+
+import (
+	"net"
+	"os"
+)
+
+// NewClientUnix returns a new OSC Client that sends packets to the Unix domain
+// datagram socket at path, e.g. one a Server listens on with
+// ListenAndServeUnix. The packets are encoded exactly as for UDP. The IP, port
+// and UDP specific options of the client are ignored.
+func NewClientUnix(path string) *Client {
+	return &Client{unixPath: path}
+}
+
+// ListenAndServeUnix listens on a Unix domain datagram socket at path and
+// dispatches the received OSC packets like ListenAndServe. The socket file is
+// removed when the connection is closed with CloseConnection. Use ServeConn
+// to serve connections accepted from a Unix domain stream socket.
+func (s *Server) ListenAndServeUnix(path string) error {
+	defer s.CloseConnection()
+
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+
+	ln, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		return err
+	}
+
+	s.close = func() error {
+		err := ln.Close()
+		if rerr := os.Remove(path); err == nil && !os.IsNotExist(rerr) {
+			err = rerr
+		}
+		return err
+	}
+
+	return s.Serve(ln)
+}
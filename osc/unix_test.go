@@ -0,0 +1,60 @@
+package osc
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("unixgram sockets are not supported on", runtime.GOOS)
+	}
+	path := filepath.Join(t.TempDir(), "osc.sock")
+
+	received := make(chan *Message, 1)
+	d := NewStandardDispatcher()
+	if err := d.AddMsgHandler("/unix", func(msg *Message) {
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Dispatcher: d}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServeUnix(path)
+	}()
+
+	// Wait for the socket to be created
+	for i := 0; ; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		} else if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client := NewClientUnix(path)
+	if err := client.Send(NewMessage("/unix", int32(42))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if got, want := msg.Arguments[0], int32(42); got != want {
+			t.Errorf("argument = %v, want = %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	if err := server.CloseConnection(); err != nil {
+		t.Fatal(err)
+	}
+	<-errc
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file wasn't removed: %v", err)
+	}
+}